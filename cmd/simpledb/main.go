@@ -0,0 +1,56 @@
+// Command simpledb manages the migrations of a simpledb database.
+// The database is configured by the DB_* environment variables, or a .env file. (See Database.GetFromEnv)
+// Commands which connect to the database report missing credentials as an error.
+// The dbshell command opens the mysql client with the SSL mode of DB_SSLMODE, like the connections of the application.
+//
+// Migration files can be applied, listed, printed and rolled back without any models.
+// Making migrations needs the models of the app, expose the commands from the app with Database.RunCommand.
+//
+//	simpledb migrate -dir ./migrations/
+//	simpledb sqlmigrate -out migration.sql Migration_2023-01-02-15-04-05
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Nigel2392/simpledb"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		simpledb.CommandUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := loadEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	db := simpledb.NewDatabase(loglevel()).GetFromEnv()
+	if err := db.RunCommand(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Load the .env file into the environment, if it exists.
+func loadEnv() error {
+	if _, err := os.Stat(".env"); err != nil {
+		return nil
+	}
+	if err := godotenv.Load(); err != nil {
+		return errors.New("failed to load .env: " + err.Error())
+	}
+	return nil
+}
+
+// Get the log level from the DB_LOGLEVEL environment variable. (Default: info)
+func loglevel() string {
+	if level := os.Getenv("DB_LOGLEVEL"); level != "" {
+		return level
+	}
+	return "info"
+}
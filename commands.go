@@ -0,0 +1,240 @@
+package simpledb
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// A subcommand of the simpledb command-line tool.
+type command struct {
+	name        string
+	usage       string
+	description string
+	// The command executes queries, a connection is opened before it runs.
+	connect bool
+	// Define the flags of the command, besides -dir.
+	flags func(flags *flag.FlagSet)
+	run   func(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error
+}
+
+// Returned by the commands which compare the registered models to the migration files, if no models are registered.
+var errNoModels = errors.New("no models registered, run the command through Database.RunCommand with the models of the app")
+
+var commands = []command{
+	{
+		name:        "makemigrations",
+		usage:       "makemigrations",
+		description: "Write a migration file if the registered models changed",
+		run:         makeMigrationsCommand,
+	},
+	{
+		name:        "migrate",
+		usage:       "migrate",
+		description: "Apply the migration files which were not applied yet",
+		connect:     true,
+		run:         migrateCommand,
+	},
+	{
+		name:        "showmigrations",
+		usage:       "showmigrations",
+		description: "List the migration files, and if they were applied",
+		connect:     true,
+		run:         showMigrationsCommand,
+	},
+	{
+		name:        "sqlmigrate",
+		usage:       "sqlmigrate [-out file.sql] [migration]",
+		description: "Print the SQL of a migration file, or of the model changes not in a migration file yet",
+		flags: func(flags *flag.FlagSet) {
+			flags.String("out", "", "write the SQL to a file instead")
+		},
+		run: sqlMigrateCommand,
+	},
+	{
+		name:        "rollback",
+		usage:       "rollback [migration]",
+		description: "Roll back the migrations applied after a migration, all migrations if omitted",
+		connect:     true,
+		run:         rollbackCommand,
+	},
+	{
+		name:        "dbshell",
+		usage:       "dbshell",
+		description: "Open the mysql client, connected to the database with the same SSL mode",
+		run:         dbShellCommand,
+	},
+}
+
+// Run a subcommand of the simpledb command-line tool, with the registered models of the database.
+// The first argument is the name of the command, a -dir flag sets the migrations directory. (Default: ./migrations/)
+// Flags have to come before the arguments of the command.
+// Apps can expose the commands to manage their own models:
+//
+//	db := simpledb.NewDatabase().LoadCredentials()
+//	db.Register(&User{})
+//	if len(os.Args) > 1 {
+//	    if err := db.RunCommand(ctx, os.Args[1:], os.Stdout); err != nil {
+//	        log.Fatal(err)
+//	    }
+//	}
+func (db *Database) RunCommand(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		CommandUsage(out)
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		flags.SetOutput(out)
+		dir := flags.String("dir", "./migrations/", "directory of the migration files")
+		flags.Usage = func() {
+			fmt.Fprintln(out, "Usage: simpledb "+cmd.usage)
+			flags.PrintDefaults()
+		}
+		if cmd.flags != nil {
+			cmd.flags(flags)
+		}
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		// Parsing stops at the first argument, flags after it would be ignored.
+		for _, arg := range flags.Args() {
+			if strings.HasPrefix(arg, "-") {
+				return errors.New("flag " + arg + " must come before the arguments: simpledb " + cmd.usage)
+			}
+		}
+		if cmd.connect && db.conn == nil {
+			if db.hasNoCredentials() {
+				return errors.New("database credentials are missing, set the DB_* environment variables")
+			}
+			if err := db.Connect(); err != nil {
+				return err
+			}
+		}
+		migration := NewMigration(db)
+		migration.Directory = *dir
+		migration.CreateFromModels(db.models)
+		return cmd.run(ctx, migration, flags, out)
+	}
+	CommandUsage(out)
+	return errors.New("unknown command " + args[0])
+}
+
+// Print the usage of the simpledb command-line tool.
+func CommandUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: simpledb <command> [-dir migrations] [flags] [arguments]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-40s %s\n", cmd.usage, cmd.description)
+	}
+}
+
+func makeMigrationsCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	if len(m.Tables) == 0 {
+		return errNoModels
+	}
+	name, err := m.Make()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		fmt.Fprintln(out, "No changes detected")
+		return nil
+	}
+	fmt.Fprintln(out, "Created migration "+name)
+	return nil
+}
+
+func migrateCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	applied, err := m.Apply(ctx)
+	if err != nil {
+		return err
+	}
+	if applied == 0 {
+		fmt.Fprintln(out, "No migrations to apply")
+		return nil
+	}
+	fmt.Fprintf(out, "Applied %d migration(s)\n", applied)
+	return nil
+}
+
+func showMigrationsCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := m.Database.AppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	done := map[string]*AppliedMigration{}
+	for _, a := range applied {
+		done[a.Name] = a
+	}
+	for _, f := range files {
+		if a, ok := done[f.Name]; ok {
+			fmt.Fprintf(out, "[X] %s (applied %s)\n", f.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Fprintf(out, "[ ] %s\n", f.Name)
+		}
+	}
+	return nil
+}
+
+func sqlMigrateCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	var plan MigrationPlan
+	var err error
+	if flags.NArg() > 0 {
+		plan, err = m.PlanFile(flags.Arg(0))
+	} else if len(m.Tables) == 0 {
+		return errNoModels
+	} else {
+		plan, err = m.PlanChanges()
+	}
+	if err != nil {
+		return err
+	}
+	if path := flags.Lookup("out").Value.String(); path != "" {
+		return plan.WriteSQL(path)
+	}
+	_, err = io.WriteString(out, plan.SQL())
+	return err
+}
+
+func rollbackCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	rolled_back, err := m.Rollback(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Rolled back %d migration(s)\n", rolled_back)
+	return nil
+}
+
+func dbShellCommand(ctx context.Context, m *Migration, flags *flag.FlagSet, out io.Writer) error {
+	db := m.Database
+	if db.hasNoCredentials() {
+		return errors.New("database credentials are missing")
+	}
+	// The client uses the same SSL mode as the connections of the application, see Database.DSN.
+	_, ssl_mode, err := db.sslModes()
+	if err != nil {
+		return err
+	}
+	shell := exec.CommandContext(ctx, "mysql", "--host="+db.Host, fmt.Sprintf("--port=%v", db.Port), "--user="+db.Username, "--ssl-mode="+ssl_mode, db.Database)
+	// The password is passed through the environment, so it does not show up in the process list.
+	shell.Env = append(os.Environ(), "MYSQL_PWD="+db.Password)
+	shell.Stdin, shell.Stdout, shell.Stderr = os.Stdin, out, os.Stderr
+	if err := shell.Run(); err != nil {
+		return errors.New("failed to run " + strings.Join(shell.Args, " ") + ": " + err.Error())
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package simpledb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -10,6 +11,9 @@ import (
 
 // Connect to the database
 func (db *Database) Connect() error {
+	if _, _, err := db.sslModes(); err != nil {
+		return err
+	}
 	var err error
 	db.conn, err = sql.Open("mysql", db.DSN())
 	db.conn.SetConnMaxLifetime(time.Minute * 3)
@@ -36,19 +40,19 @@ func (db *Database) Ping() error {
 // Query the database
 func (db *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	db.Logger.Debug("QUERY: ", query, args)
-	return db.conn.Query(query, args...)
+	return db.executor().QueryContext(context.Background(), query, args...)
 }
 
 // Query a database row
 func (db *Database) QueryRow(query string, args ...interface{}) *sql.Row {
 	db.Logger.Debug("QUERYROW: ", query, args)
-	return db.conn.QueryRow(query, args...)
+	return db.executor().QueryRowContext(context.Background(), query, args...)
 }
 
 // Exec a query
 func (db *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
 	db.Logger.Debug("EXEC: ", query, args)
-	return db.conn.Exec(query, args...)
+	return db.executor().ExecContext(context.Background(), query, args...)
 }
 
 // Begin a transaction
@@ -56,27 +60,83 @@ func (db *Database) Begin() (*sql.Tx, error) {
 	return db.conn.Begin()
 }
 
+// Run a function inside of a transaction.
+// The database passed to the function executes all queries inside of the transaction.
+// If the function returns an error, or panics, the transaction is rolled back.
+// Otherwise the transaction is committed.
+// Calling Transaction on a database which is already in a transaction reuses that transaction.
+func (db *Database) Transaction(ctx context.Context, fn func(tx *Database) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+	sqltx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var txdb = *db
+	txdb.tx = sqltx
+	defer func() {
+		if r := recover(); r != nil {
+			sqltx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(&txdb); err != nil {
+		db.Logger.Debug("Rolling back transaction: ", err.Error())
+		if rb_err := sqltx.Rollback(); rb_err != nil {
+			return errors.New(err.Error() + ": failed to roll back transaction: " + rb_err.Error())
+		}
+		return err
+	}
+	return sqltx.Commit()
+}
+
+// Check if the database is currently in a transaction.
+func (db *Database) InTransaction() bool {
+	return db.tx != nil
+}
+
 // Prepare a query
 func (db *Database) Prepare(query string) (*sql.Stmt, error) {
-	return db.conn.Prepare(query)
+	return db.executor().PrepareContext(context.Background(), query)
 }
 
 // Prepare a query with context
 func (db *Database) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
-	return db.conn.PrepareContext(ctx, query)
+	return db.executor().PrepareContext(ctx, query)
 }
 
 // Exec a query with context
 func (db *Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.conn.ExecContext(ctx, query, args...)
+	db.Logger.Debug("EXEC: ", query, args)
+	return db.executor().ExecContext(ctx, query, args...)
 }
 
 // Query the database with context
 func (db *Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return db.conn.QueryContext(ctx, query, args...)
+	db.Logger.Debug("QUERY: ", query, args)
+	return db.executor().QueryContext(ctx, query, args...)
 }
 
 // Query the database row with context
 func (db *Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return db.conn.QueryRowContext(ctx, query, args...)
+	db.Logger.Debug("QUERYROW: ", query, args)
+	return db.executor().QueryRowContext(ctx, query, args...)
+}
+
+// Executor is implemented by both *sql.DB and *sql.Tx.
+type executor interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Get the executor for the database,
+// this is the transaction if one is active.
+func (db *Database) executor() executor {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.conn
 }
@@ -1,9 +1,11 @@
 package simpledb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Nigel2392/simplelog"
 	"github.com/go-sql-driver/mysql"
@@ -41,9 +43,25 @@ type Database struct {
 	SSL_MODE        string
 	LIMIT           int
 	conn            *sql.DB          `json:"-"`
+	tx              *sql.Tx          `json:"-"`
 	models          []Model          `json:"-"`
 	LatestMigration *Migration       `json:"-"`
 	Logger          simplelog.Logger `json:"-"`
+	// Clock is used to fill AUTO_NOW and AUTO_NOW_ADD fields.
+	// Can be replaced for testing purposes.
+	Clock func() time.Time `json:"-"`
+	// Validate models before inserting or updating them.
+	// See ValidateModel for more information.
+	ValidateOnSave bool `json:"-"`
+	// NamingStrategy decides the column names of registered models. (Default: SnakeCase)
+	// Set it to LowerCase for tables created before the naming strategy was introduced.
+	// Must be set before registering any models.
+	NamingStrategy NamingStrategy `json:"-"`
+	// KeyProvider provides the keys for ENCRYPTED fields.
+	KeyProvider KeyProvider `json:"-"`
+	// Encryptor used for ENCRYPTED fields.
+	// Defaults to AES-GCM with the keys from the KeyProvider.
+	Encryptor Encryptor `json:"-"`
 }
 
 // Connect to the database
@@ -64,6 +82,8 @@ func NewDatabase(loglevel ...string) *Database {
 		LIMIT:           1000,
 		LatestMigration: &latest_migration,
 		Logger:          logger,
+		Clock:           time.Now,
+		NamingStrategy:  DefaultNamingStrategy,
 	}
 }
 
@@ -72,20 +92,35 @@ func (db *Database) String() string {
 	return fmt.Sprintf("Database: %s", db.Database)
 }
 
+// Get the current time from the database clock.
+func (db *Database) Now() time.Time {
+	if db.Clock == nil {
+		return time.Now()
+	}
+	return db.Clock()
+}
+
 // Register a model with the database
 // This will be used to create the table if it doesn't exist,
-// and to create the migration if it doesn't exist
+// and to create the migration if it doesn't exist.
+// The metadata of the model is computed and cached here, see GetMeta.
 func (db *Database) Register(model Model) {
 	db.Logger.Debug("Registering model: " + model.TableName())
+	db.meta(model)
 	db.models = append(db.models, model)
 }
 
 // Migrate the database to the latest version
 func (db *Database) Migrate() error {
+	return db.MigrateContext(context.Background())
+}
+
+// See Migrate.
+func (db *Database) MigrateContext(ctx context.Context) error {
 	db.Logger.Info("Initializing migration")
 	migration := NewMigration(db)
 	migration.CreateFromModels(db.models)
-	return migration.Run()
+	return migration.RunContext(ctx)
 }
 
 // Shorthand for a queryset
@@ -116,3 +151,13 @@ func CheckSQLError(err error, number int) bool {
 func (db *Database) AllModels() []Model {
 	return db.models
 }
+
+// Get a registered model by its table name.
+func (db *Database) ModelByTable(table string) (Model, bool) {
+	for _, model := range db.models {
+		if model.TableName() == table {
+			return model, true
+		}
+	}
+	return nil, false
+}
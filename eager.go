@@ -0,0 +1,329 @@
+package simpledb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Load to-one relations in the same query, using LEFT JOINs.
+// The related models are set on the Rel_ fields of the returned models.
+// Only belongs-to and one to one relations can be selected, use Prefetch for many to many relations.
+// Call SelectRelated before OrderBy, GroupBy or Select, so their columns are qualified with the table name.
+// Example:
+//
+//	qs.SelectRelated("customer").All().Where("total", ">", 10).MultiModel()
+func (q *QuerySet) SelectRelated(names ...string) *QuerySet {
+	for _, name := range names {
+		f := q.relation(name)
+		if t := relationType(f.Tags.RelType()); (t != relBelongsTo && t != relOneToOne) || f.Type.Kind() != reflect.Ptr {
+			panic("relation " + name + " is not a to-one relation, use Prefetch instead")
+		}
+		q.related = append(q.related, f)
+	}
+	return q
+}
+
+// Load relations with one extra query per relation, after the models have been fetched.
+// The related models are set on the Rel_ fields of the returned models.
+// All relation types are supported, many to many relations are loaded into slice fields.
+// Example:
+//
+//	qs.Prefetch("model_two").All().MultiModel()
+func (q *QuerySet) Prefetch(names ...string) *QuerySet {
+	for _, name := range names {
+		q.prefetch = append(q.prefetch, q.relation(name))
+	}
+	return q
+}
+
+// Get a relation of the model of the QuerySet by name.
+// Panics if the relation does not exist.
+func (q *QuerySet) relation(name string) *FieldMeta {
+	if q.Model == nil {
+		panic("no model provided, cannot load relation " + name)
+	}
+	meta := q.db.meta(q.Model)
+	f, ok := meta.Relation(name)
+	if !ok {
+		panic("no relation " + name + " on model " + meta.Table)
+	}
+	if _, err := f.RelatedMeta(); err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Get the columns to select for the related models.
+// Columns of related models are prefixed with the relation name. (rel__customer.name AS customer__name)
+func (q *QuerySet) relatedColumns(meta *ModelMeta) []string {
+	columns := []string{}
+	for _, column := range meta.ColumnNames() {
+		columns = append(columns, meta.Table+`.`+column)
+	}
+	for _, f := range q.related {
+		related, _ := f.RelatedMeta()
+		name := relationName(f)
+		alias := joinAlias(name)
+		for _, column := range related.ColumnNames() {
+			columns = append(columns, alias+`.`+column+` AS `+name+`__`+column)
+		}
+	}
+	if q.distinct {
+		// Columns in the ORDER BY clause must be selected with DISTINCT.
+		columns = append(columns, q.orderedColumns(columns)...)
+	}
+	return columns
+}
+
+// Get the columns in the ORDER BY clause which are not in the selected columns.
+// The columns are aliased like the columns of related models, they are not scanned into the model.
+func (q *QuerySet) orderedColumns(selected []string) []string {
+	columns := []string{}
+	selected = append([]string{}, selected...)
+	for _, column := range q.ordered {
+		found := false
+		for _, s := range selected {
+			if s == column || strings.HasPrefix(s, column+` AS `) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			aliased := column + ` AS ` + strings.Replace(column, `.`, `__`, 1)
+			columns = append(columns, aliased)
+			selected = append(selected, aliased)
+		}
+	}
+	return columns
+}
+
+// Get the JOIN statements for the related models.
+func (q *QuerySet) relatedJoins(meta *ModelMeta) []string {
+	joins := []string{}
+	for _, f := range q.related {
+		clauses, _ := relationJoins(meta, meta.Table, f, joinAlias(relationName(f)))
+		joins = append(joins, clauses...)
+	}
+	return joins
+}
+
+// Scan the rows into models, setting the selected related models.
+func (q *QuerySet) scanModels(ctx context.Context, rows *sql.Rows) (ModelSet, error) {
+	if len(q.related) == 0 {
+		return scanRows(ctx, q.db, q.db.meta(q.Model), rows, q.Model, q.exclude), nil
+	}
+	var models ModelSet
+	for rows.Next() {
+		model := NewModel(q.Model)
+		if err := q.scan(ctx, model, rows); err != nil {
+			return nil, err
+		}
+		if err := afterFind(ctx, model); err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
+
+// Scan the current row into a model, setting the selected related models.
+func (q *QuerySet) scan(ctx context.Context, model Model, rows *sql.Rows) error {
+	meta := q.db.meta(model)
+	if len(q.related) == 0 {
+		return scan(q.db, meta, model, rows, q.exclude)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(model).Elem()
+	fields, finish := scanTargets(q.db, meta, model, columns, q.exclude)
+	finishers := []func() error{}
+	for _, f := range q.related {
+		var f = f
+		var related, _ = f.RelatedMeta()
+		var prefix = relationName(f) + `__`
+		var rel = reflect.New(related.Type)
+		var rel_columns = make([]string, len(columns))
+		for i, column := range columns {
+			if strings.HasPrefix(column, prefix) {
+				rel_columns[i] = strings.TrimPrefix(column, prefix)
+			}
+		}
+		rel_fields, found := scanNullableTargets(q.db, related, rel.Interface().(Model), rel_columns)
+		for i, column := range rel_columns {
+			if column != "" {
+				fields[i] = rel_fields[i]
+			}
+		}
+		finishers = append(finishers, func() error {
+			if !found() {
+				return nil
+			}
+			f.value(value).Set(rel)
+			return afterFind(ctx, rel.Interface().(Model))
+		})
+	}
+	if err := rows.Scan(fields...); err != nil {
+		return err
+	}
+	finish()
+	for _, fn := range finishers {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load the prefetched relations of the models.
+func (q *QuerySet) prefetchRelated(models ModelSet) error {
+	if len(models) == 0 {
+		return nil
+	}
+	meta := q.db.meta(q.Model)
+	for _, f := range q.prefetch {
+		var err error
+		switch {
+		case f.ForeignKey:
+			err = q.db.prefetchForeignKey(f, models)
+		case relationType(f.Tags.RelType()) == relOneToMany:
+			err = q.db.prefetchChildren(q.context(), meta, f, models)
+		default:
+			err = q.db.prefetchJoin(q.context(), meta, f, models)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load a belongs-to relation of the models with a single IN query.
+func (db *Database) prefetchForeignKey(f *FieldMeta, models ModelSet) error {
+	related, _ := f.RelatedMeta()
+	keys := []any{}
+	seen := map[string]bool{}
+	for _, model := range models {
+		key := foreignKeyValue(f, reflect.ValueOf(model).Elem())
+		if key == nil || seen[linkKey(key)] {
+			continue
+		}
+		seen[linkKey(key)] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	found, err := db.selectIn(reflect.New(related.Type).Interface().(Model), related.PrimaryColumn(), keys)
+	if err != nil {
+		return err
+	}
+	for _, model := range models {
+		value := reflect.ValueOf(model).Elem()
+		key := foreignKeyValue(f, value)
+		if key == nil {
+			continue
+		}
+		if rel, ok := found[linkKey(key)]; ok {
+			f.value(value).Set(reflect.ValueOf(rel))
+		}
+	}
+	return nil
+}
+
+// Load a one to many relation with a single IN query on the child table.
+func (db *Database) prefetchChildren(ctx context.Context, meta *ModelMeta, f *FieldMeta, models ModelSet) error {
+	related, _ := f.RelatedMeta()
+	column := reverseColumn(meta, f)
+	parents, keys := prefetchParents(meta, models)
+	filters := Filters{}.Add(related.Table+`.`+column, IN, keys)
+	f_query, args := filters.Query(true)
+	query := fmt.Sprintf(`SELECT %s.*, %s.%s AS prefetch__key FROM %s`,
+		related.Table, related.Table, column, related.Table) + f_query
+	return db.prefetchRows(ctx, f, related, parents, query, args)
+}
+
+// Load a relation stored in a join table with a single IN query.
+// The join table is queried together with the related table,
+// the models are matched on the column pointing to the owning model.
+func (db *Database) prefetchJoin(ctx context.Context, meta *ModelMeta, f *FieldMeta, models ModelSet) error {
+	related, _ := f.RelatedMeta()
+	table, from_column, to_column := joinTable(meta, f)
+	parents, keys := prefetchParents(meta, models)
+	filters := Filters{}.Add(`link.`+from_column, IN, keys)
+	f_query, args := filters.Query(true)
+	query := fmt.Sprintf(`SELECT %s.*, link.%s AS prefetch__key FROM %s JOIN %s AS link ON link.%s = %s.%s`,
+		related.Table, from_column, related.Table, table, to_column, related.Table, related.PrimaryColumn()) + f_query
+	return db.prefetchRows(ctx, f, related, parents, query, args)
+}
+
+// Get the models to prefetch relations for by their primary key normalized with linkKey,
+// together with the distinct primary keys.
+func prefetchParents(meta *ModelMeta, models ModelSet) (map[string]reflect.Value, []any) {
+	parents := map[string]reflect.Value{}
+	keys := []any{}
+	for _, model := range models {
+		_, key := primaryKey(meta, model)
+		if _, ok := parents[linkKey(key)]; ok {
+			continue
+		}
+		parents[linkKey(key)] = reflect.ValueOf(model).Elem()
+		keys = append(keys, key)
+	}
+	return parents, keys
+}
+
+// Execute a prefetch query, and set the related models on the relation field of their parents.
+// The last column of the query must hold the primary key of the parent. (prefetch__key)
+// Slice fields are emptied first, so prefetching again does not add the related models twice.
+func (db *Database) prefetchRows(ctx context.Context, f *FieldMeta, related *ModelMeta, parents map[string]reflect.Value, query string, args []any) error {
+	for _, parent := range parents {
+		if field := f.value(parent); field.Kind() == reflect.Slice {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var key any
+		var rel = reflect.New(related.Type)
+		var fields, finish = scanTargets(db, related, rel.Interface().(Model), columns, nil)
+		fields[len(fields)-1] = &key
+		if err := rows.Scan(fields...); err != nil {
+			return err
+		}
+		finish()
+		if err := afterFind(ctx, rel.Interface().(Model)); err != nil {
+			return err
+		}
+		parent, ok := parents[linkKey(key)]
+		if !ok {
+			continue
+		}
+		field := f.value(parent)
+		switch field.Kind() {
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.Ptr {
+				field.Set(reflect.Append(field, rel))
+			} else {
+				field.Set(reflect.Append(field, rel.Elem()))
+			}
+		case reflect.Ptr:
+			if field.IsNil() {
+				field.Set(rel)
+			}
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,251 @@
+package simpledb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// KeyProvider provides the keys used to encrypt ENCRYPTED fields.
+// Keys are identified by an ID, which is stored alongside the ciphertext.
+// This allows keys to be rotated, while still being able to decrypt old values.
+type KeyProvider interface {
+	// The ID and key used to encrypt new values.
+	CurrentKey() (id string, key []byte, err error)
+	// Get a key by its ID, used to decrypt values.
+	Key(id string) ([]byte, error)
+	// The key used to compute blind indexes, used for equality lookups.
+	BlindIndexKey() ([]byte, error)
+}
+
+// Encryptor encrypts and decrypts the values of ENCRYPTED fields.
+type Encryptor interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// Maximum length of a key ID.
+const maxKeyIDLength = 32
+
+// Overhead of encrypting a value with AES-GCM.
+// Key ID length + key ID + nonce + tag.
+const encryptionOverhead = 1 + maxKeyIDLength + 12 + 16
+
+// StaticKeys is a KeyProvider with a fixed set of keys.
+// Keys must be 16, 24 or 32 bytes long. (AES-128, AES-192 or AES-256)
+type StaticKeys struct {
+	// ID of the key used to encrypt new values
+	Current string
+	// Keys by ID
+	Keys map[string][]byte
+	// Key used to compute blind indexes
+	IndexKey []byte
+}
+
+// The ID and key used to encrypt new values.
+func (k *StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+// Get a key by its ID.
+func (k *StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, errors.New("encryption key not found: " + id)
+	}
+	return key, nil
+}
+
+// The key used to compute blind indexes.
+func (k *StaticKeys) BlindIndexKey() ([]byte, error) {
+	if len(k.IndexKey) == 0 {
+		return nil, errors.New("no blind index key provided")
+	}
+	return k.IndexKey, nil
+}
+
+// AESGCM is the default Encryptor.
+// Values are stored as: [len(key ID)][key ID][nonce][ciphertext + tag]
+type AESGCM struct {
+	Keys KeyProvider
+}
+
+// Encrypt a value with the current key.
+func (a *AESGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	id, key, err := a.Keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > maxKeyIDLength {
+		return nil, errors.New("encryption key ID is too long: " + id)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(id)+gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// Decrypt a value with the key it was encrypted with.
+func (a *AESGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1 || len(ciphertext) < 1+int(ciphertext[0]) {
+		return nil, errors.New("invalid ciphertext")
+	}
+	id := string(ciphertext[1 : 1+int(ciphertext[0])])
+	ciphertext = ciphertext[1+int(ciphertext[0]):]
+	key, err := a.Keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+}
+
+// Initialize AES-GCM with a key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Returned when an encrypted field is used without a database to get the keys from.
+// The methods of a Database use its own keys, the package level helpers use the database the model was registered with.
+var errNoEncryptionDB = errors.New("encrypted fields need a database with a key provider, use the methods of a Database")
+
+// Get the encryptor of the database.
+// Defaults to AES-GCM with the database's key provider.
+func (db *Database) encryptor() (Encryptor, error) {
+	if db == nil {
+		return nil, errNoEncryptionDB
+	}
+	if db.Encryptor != nil {
+		return db.Encryptor, nil
+	}
+	if db.KeyProvider == nil {
+		return nil, errors.New("no key provider set on the database")
+	}
+	return &AESGCM{Keys: db.KeyProvider}, nil
+}
+
+// Compute the blind index of a value, used for equality lookups on encrypted fields.
+func (db *Database) BlindIndex(value any) ([]byte, error) {
+	if db == nil {
+		return nil, errNoEncryptionDB
+	}
+	if db.KeyProvider == nil {
+		return nil, errors.New("no key provider set on the database")
+	}
+	key, err := db.KeyProvider.BlindIndexKey()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := plainBytes(reflect.ValueOf(value))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(plaintext)
+	return mac.Sum(nil), nil
+}
+
+// Get the bytes of a string or []byte value.
+func plainBytes(v reflect.Value) ([]byte, error) {
+	switch {
+	case v.Kind() == reflect.String:
+		return []byte(v.String()), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return v.Bytes(), nil
+	}
+	return nil, errors.New("only string and []byte fields can be encrypted")
+}
+
+// Encrypt the value of a field.
+// Nil pointers are stored as NULL.
+func encryptedValue(db *Database, field reflect.Value) (any, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+	plaintext, err := plainBytes(field)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := db.encryptor()
+	if err != nil {
+		return nil, err
+	}
+	return enc.Encrypt(plaintext)
+}
+
+// Compute the blind index of a field.
+// Nil pointers are stored as NULL.
+func blindIndexValue(db *Database, field reflect.Value) (any, error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+	return db.BlindIndex(field.Interface())
+}
+
+// Scanner which decrypts an encrypted column into a struct field.
+type encryptedScanner struct {
+	dest reflect.Value
+	db   *Database
+}
+
+// Decrypt the column into the destination.
+func (s *encryptedScanner) Scan(src any) error {
+	var field = s.dest
+	if src == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	ciphertext, ok := src.([]byte)
+	if !ok {
+		return errors.New("cannot scan encrypted column from " + reflect.TypeOf(src).String())
+	}
+	enc, err := s.db.encryptor()
+	if err != nil {
+		return err
+	}
+	plaintext, err := enc.Decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if field.Kind() == reflect.String {
+		field.SetString(string(plaintext))
+	} else {
+		field.SetBytes(plaintext)
+	}
+	return nil
+}
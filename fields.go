@@ -5,6 +5,7 @@ import (
 	"errors"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/Nigel2392/typeutils"
 )
@@ -28,6 +29,8 @@ func GetColType(typ string, dialect string) string {
 			return "INTEGER"
 		case "int64":
 			return "INTEGER"
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			return "INTEGER"
 		case "float32":
 			return "FLOAT"
 		case "float64":
@@ -43,17 +46,17 @@ func GetColType(typ string, dialect string) string {
 		switch typ {
 		case "string":
 			return "VARCHAR"
-		case "int":
+		case "int", "uint", "uint32":
 			return "INT"
 		case "bool":
 			return "BOOLEAN"
-		case "int8":
+		case "int8", "uint8":
 			return "TINYINT"
-		case "int16":
+		case "int16", "uint16":
 			return "SMALLINT"
 		case "int32":
 			return "INT"
-		case "int64":
+		case "int64", "uint64":
 			return "BIGINT"
 		case "float32":
 			return "FLOAT"
@@ -79,152 +82,329 @@ func GetColTypes(types []string, dialect string) []string {
 }
 
 // Get the model columns, excluding related fields.
+// The foreign key columns of belongs-to relations are included.
 // Optionally, you can specify a list of columns to exclude.
 func Columns(model any, exclude ...string) []string {
-	// Validate kind
-	kind := modelKind(model)
-	// Loop through all fields in the struct
-	columns := []string{}
-	inlineLoopFields(kind, func(f reflect.StructField, i int) {
-		if isRelated(f) {
-			return
-		}
-		if typeutils.Contains(exclude, strings.ToLower(f.Name)) {
-			return
+	return GetMeta(model).columns(exclude...)
+}
+
+// Get the model columns, with the naming strategy of the database. See Columns.
+func (db *Database) Columns(model any, exclude ...string) []string {
+	return db.meta(model).columns(exclude...)
+}
+
+// Get the column names of the model, excluding the given columns. See Columns.
+func (meta *ModelMeta) columns(exclude ...string) []string {
+	columns := make([]string, 0, len(meta.Columns)+len(meta.ForeignKeys))
+	for _, column := range meta.ColumnNames() {
+		if typeutils.Contains(exclude, column) {
+			continue
 		}
-		// Get the name of the struct field
-		columns = append(columns, strings.ToLower(f.Name))
-	})
+		columns = append(columns, column)
+	}
 	return columns
 }
 
 // Get all of the model columns, including related fields.
 // Optionally, you can specify a list of columns to exclude.
 func AllColumns(model any, exclude ...string) []string {
-	// Validate kind
-	kind := modelKind(model)
-	// Loop through all fields in the struct
-	columns := []string{}
-	inlineLoopFields(kind, func(f reflect.StructField, i int) {
-		if typeutils.Contains(exclude, strings.ToLower(f.Name)) {
-			return
+	meta := GetMeta(model)
+	columns := make([]string, 0, len(meta.Fields))
+	for _, f := range meta.Fields {
+		if typeutils.Contains(exclude, f.Column) {
+			continue
 		}
-		// Get the name of the struct field
-		columns = append(columns, strings.ToLower(f.Name))
-	})
+		columns = append(columns, f.Column)
+	}
 	return columns
 }
 
 // Get the columns needed for a migration
 func MigrationColumns(model Model) []Column {
-	kind := modelKind(model)
-	columns := []Column{}
-	inlineLoopFields(kind, func(f reflect.StructField, i int) {
-		if isRelated(f) {
-			return //Skip related fields
-		}
-		var tv ModelTags = TagMap(f)
-		typ := GetColType(f.Type.Name(), "mysql")
-		col := tv.ToColumn(model.TableName(), strings.ToLower(f.Name), typ)
+	return migrationColumns(GetMeta(model), model)
+}
+
+// See MigrationColumns.
+func migrationColumns(meta *ModelMeta, model Model) []Column {
+	columns := make([]Column, 0, len(meta.Columns))
+	for _, f := range meta.Columns {
+		typ, _, unsigned := GoColumnType(f.Type)
+		col := f.Tags.ToColumn(model.TableName(), f.Column, string(typ))
+		col.Nullable = f.nullable()
+		col.Unsigned = col.Unsigned || unsigned
 		columns = append(columns, col)
-	})
+		if f.Tags.Encrypted() && f.Tags.BlindIndex() != "" {
+			columns = append(columns, Column{
+				Table:    model.TableName(),
+				Name:     f.Tags.BlindIndex(),
+				Type:     BINARY,
+				Length:   32,
+				Nullable: col.Nullable,
+			})
+		}
+	}
+	for _, f := range meta.ForeignKeys {
+		columns = append(columns, foreignKeyColumn(model.TableName(), f))
+	}
 	return columns
 }
 
+// Get the column of a belongs-to relation.
+// The column has the same type as the primary key of the related model.
+func foreignKeyColumn(table string, f *FieldMeta) Column {
+	related, _ := f.RelatedMeta()
+	col := referenceColumn(table, f.Column, related, f.Tags.Nullable())
+	col.Index = f.Tags.Index()
+	col.Tags = f.Tags
+	return col
+}
+
+// Get a column which references the primary key of a model.
+// The column has the same type as the primary key, defaults to BIGINT.
+func referenceColumn(table, name string, related *ModelMeta, nullable bool) Column {
+	col := Column{Type: BIGINT}
+	if related != nil && related.Primary != nil {
+		typ, _, unsigned := GoColumnType(related.Primary.Type)
+		col = related.Primary.Tags.ToColumn(related.Table, related.Primary.Column, string(typ))
+		col.Unsigned = col.Unsigned || unsigned
+	}
+	return Column{
+		Table:     table,
+		Name:      name,
+		Type:      col.Type,
+		Length:    col.Length,
+		Precision: col.Precision,
+		Scale:     col.Scale,
+		Unsigned:  col.Unsigned,
+		Nullable:  nullable,
+	}
+}
+
 // Get the related fields for migrating a model.
 func MigrationRelations(model Model) []Relation {
-	kind := modelKind(model)
-	relations := []Relation{}
-	for i := 0; i < kind.NumField(); i++ {
-		f := kind.Field(i)
+	return migrationRelations(GetMeta(model), model)
+}
 
-		if f.Tag.Get(TAG) == "" {
-			continue
-		} else if f.Tag.Get(TAG) == "-" {
-			continue
+// See MigrationRelations.
+func migrationRelations(meta *ModelMeta, model Model) []Relation {
+	relations := make([]Relation, 0, len(meta.Relations))
+	for _, f := range meta.Relations {
+		relation := Relation{
+			From:     model.TableName(),
+			To:       relationTarget(f), //Provide the name of the other table
+			Type:     DBType(f.Tags.RelType()),
+			Through:  f.Tags.Through(),
+			OnDelete: f.Tags.OnDelete(),
+			OnUpdate: f.Tags.OnUpdate(),
 		}
-		if isRelated(f) {
-			tm := TagMap(f)
-			other := strings.TrimPrefix(f.Name, "Rel_")
-			relations = append(relations, Relation{
-				From: model.TableName(),
-				To:   other, //Provide the name of the other table
-				Type: DBType(tm.RelType()),
-			})
+		if name := relationName(f); name != relation.To {
+			relation.Name = name
+		}
+		if f.ForeignKey {
+			relation.Column = f.Column
+			relation.References = "id"
+			if related, err := f.RelatedMeta(); err == nil && related.Primary != nil {
+				relation.References = related.Primary.Column
+			}
+		}
+		if t := relationType(f.Tags.RelType()); (t == relManyToMany || t == relOneToOne) && relation.Through == "" {
+			related, _ := f.RelatedMeta()
+			relation.FromReferences = meta.PrimaryColumn()
+			relation.FromType = referenceColumn("", "", meta, false).typeString()
+			relation.References = "id"
+			if related != nil {
+				relation.References = related.PrimaryColumn()
+			}
+			relation.ToType = referenceColumn("", "", related, false).typeString()
 		}
+		if relationType(f.Tags.RelType()) == relOneToMany {
+			relation.Column = reverseColumn(meta, f)
+			relation.References = meta.PrimaryColumn()
+			relation.Definition = referenceColumn(relation.To, relation.Column, meta, true).String()
+			// The child model owns the foreign key column if it declares it.
+			// A belongs-to relation on the child has a constraint of its own,
+			// the relation is then only used to query the children.
+			// For other columns, only the constraint is added.
+			if child, err := f.RelatedMeta(); err == nil {
+				if field, ok := child.Field(relation.Column); ok && field.ForeignKey {
+					continue
+				} else if ok {
+					relation.Definition = ""
+					relation.Declared = true
+				}
+			}
+		}
+		relations = append(relations, relation)
 	}
 	return relations
 }
 
 // Get the columns with golang types
+// The types are formatted as in Go source, such as int64, *string or sql.NullString.
 func ColumnsWithTypes(model any) ([]string, []string) {
-	// Validate kind
-	kind := modelKind(model)
+	meta := GetMeta(model)
 	// Columns to return
-	types := make([]string, 0)
-	columns := []string{}
-	inlineLoopFields(kind, func(f reflect.StructField, i int) {
-		if isRelated(f) {
-			return //Skip related fields
-		}
+	types := make([]string, 0, len(meta.Columns))
+	columns := make([]string, 0, len(meta.Columns))
+	for _, f := range meta.Columns {
 		// Get the name of the struct field
-		columns = append(columns, strings.ToLower(f.Name))
-		// Get the type of the struct field
-		types = append(types, f.Type.Name())
-	})
+		columns = append(columns, f.Column)
+		// Get the type of the struct field, including the package and pointer or slice prefixes (*string, sql.NullString)
+		types = append(types, f.Type.String())
+	}
 	return columns, types
 }
 
 // Get a value from a model struct
 func GetValue(model Model, column string) any {
-	// Validate kind
-	kind := modelKind(model)
-	// Loop through all fields in the struct
-	for i := 0; i < kind.NumField(); i++ {
-		f_kind := kind.Field(i)
-		if !TagValid(f_kind) {
-			continue
+	return getValue(GetMeta(model), model, column)
+}
+
+// Get a value from a model struct, by a column name of the naming strategy of the database. See GetValue.
+func (db *Database) GetValue(model Model, column string) any {
+	return getValue(db.meta(model), model, column)
+}
+
+// See GetValue.
+func getValue(meta *ModelMeta, model Model, column string) any {
+	f, ok := meta.Field(column)
+	if !ok {
+		return nil
+	}
+	// Related fields are loaded with QuerySet.SelectRelated or QuerySet.Prefetch,
+	// the value of the field is returned as is.
+	if f.ForeignKey {
+		// Belongs-to relations return the primary key of the related model
+		return foreignKeyValue(f, reflect.ValueOf(model).Elem())
+	}
+	val := f.value(reflect.ValueOf(model).Elem())
+	if f.Type.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
 		}
-		// Get the name of the struct field
-		if strings.EqualFold(f_kind.Name, column) {
-			var val any
-			// var err error
-			//if isRelated(f_kind) {
-			//	// TODO: Handle related fields
-			//	// Query the related table
-			//	// Get the value of the related fields
-			//	continue
-			//}
-			if f_kind.Type.Kind() == reflect.Ptr {
-				val = reflect.ValueOf(model).Elem().Field(i).Elem().Interface()
-			} else {
-				val = reflect.ValueOf(model).Elem().Field(i).Interface()
+		val = val.Elem()
+	}
+	return val.Interface()
+}
+
+// Get the values of the columns to store in the database.
+// Unlike GetValue, JSON fields are marshaled, and ENCRYPTED fields are encrypted.
+// ENCRYPTED fields need the keys of a database, use Database.DBValues for models with ENCRYPTED fields.
+func DBValues(model Model, columns []string) ([]any, error) {
+	return dbValues(nil, GetMeta(model), model, columns)
+}
+
+// Get the values of the columns to store in the database,
+// with the naming strategy and the keys of the database. See DBValues.
+func (db *Database) DBValues(model Model, columns []string) ([]any, error) {
+	return dbValues(db, db.meta(model), model, columns)
+}
+
+// See DBValues.
+func dbValues(db *Database, meta *ModelMeta, model Model, columns []string) ([]any, error) {
+	value := reflect.ValueOf(model).Elem()
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		f, ok := meta.Field(column)
+		switch {
+		case ok && f.Tags.Encrypted():
+			v, err := encryptedValue(db, f.value(value))
+			if err != nil {
+				return nil, errors.New("failed to encrypt " + f.Column + ": " + err.Error())
+			}
+			values[i] = v
+		case ok && f.Tags.JSON():
+			v, err := jsonValue(f.value(value), f.Tags.Nullable())
+			if err != nil {
+				return nil, errors.New("failed to marshal " + f.Column + ": " + err.Error())
 			}
-			return val
+			values[i] = v
+		default:
+			values[i] = getValue(meta, model, column)
 		}
 	}
-	return nil
+	return values, nil
 }
 
-// Set a value on a model struct
-func SetValue(model Model, column string, value any) {
-	// Validate kind
-	kind := modelKind(model)
-	// Loop through all fields in the struct
-	for i := 0; i < kind.NumField(); i++ {
-		f_kind := kind.Field(i)
-		if !TagValid(f_kind) {
+// Get the columns and values to insert or update a model with.
+// This includes the blind index columns of ENCRYPTED fields.
+// ENCRYPTED fields need the keys of a database, use Database.ModelValues for models with ENCRYPTED fields.
+func ModelValues(model Model) ([]string, []any, error) {
+	return modelValues(nil, GetMeta(model), model)
+}
+
+// Get the columns and values to insert or update a model with,
+// with the naming strategy and the keys of the database. See ModelValues.
+func (db *Database) ModelValues(model Model) ([]string, []any, error) {
+	return modelValues(db, db.meta(model), model)
+}
+
+// See ModelValues.
+func modelValues(db *Database, meta *ModelMeta, model Model) ([]string, []any, error) {
+	columns := meta.columns()
+	values, err := dbValues(db, meta, model, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range meta.Columns {
+		if !f.Tags.Encrypted() || f.Tags.BlindIndex() == "" {
 			continue
 		}
-		if isRelated(f_kind) {
+		v, err := blindIndexValue(db, f.value(reflect.ValueOf(model).Elem()))
+		if err != nil {
+			return nil, nil, errors.New("failed to compute blind index for " + f.Column + ": " + err.Error())
+		}
+		columns = append(columns, f.Tags.BlindIndex())
+		values = append(values, v)
+	}
+	return columns, values, nil
+}
+
+// Set a value on a model struct
+func SetValue(model Model, column string, value any) {
+	setValue(GetMeta(model), model, column, value)
+}
+
+// Set a value on a model struct, by a column name of the naming strategy of the database. See SetValue.
+func (db *Database) SetValue(model Model, column string, value any) {
+	setValue(db.meta(model), model, column, value)
+}
+
+// See SetValue.
+func setValue(meta *ModelMeta, model Model, column string, value any) {
+	f, ok := meta.Field(column)
+	if ok && f.ForeignKey {
+		// Belongs-to relations are set to a model with only the primary key set
+		setForeignKey(f, reflect.ValueOf(model).Elem(), value)
+		return
+	}
+	if !ok || f.Related {
+		return
+	}
+	// Set the value of the struct field
+	// Convert the value if types do not match
+	v := reflect.ValueOf(value)
+	if v.Type() != f.Type && v.Type().ConvertibleTo(f.Type) {
+		v = v.Convert(f.Type)
+	}
+	f.value(reflect.ValueOf(model).Elem()).Set(v)
+}
+
+// Set the AUTO_NOW fields on a model to the given time.
+// When inserting, AUTO_NOW_ADD fields will also be set.
+func SetTimestamps(model Model, now time.Time, insert bool) {
+	meta := GetMeta(model)
+	for _, f := range meta.Columns {
+		if !f.Tags.AutoNow() && !(insert && f.Tags.AutoNowAdd()) {
 			continue
 		}
-		// Get the name of the struct field
-		if strings.EqualFold(f_kind.Name, column) {
-			// Set the value of the struct field
-			// Check if types match
-			reflect.ValueOf(model).Elem().Field(i).Set(reflect.ValueOf(value))
-			return
+		field := f.value(reflect.ValueOf(model).Elem())
+		switch f.Type {
+		case reflect.TypeOf(time.Time{}):
+			field.Set(reflect.ValueOf(now))
+		case reflect.TypeOf(&time.Time{}):
+			var t = now
+			field.Set(reflect.ValueOf(&t))
 		}
 	}
 }
@@ -234,37 +414,152 @@ func isRelated(f reflect.StructField) bool {
 	return strings.HasPrefix(strings.ToLower(f.Name), "rel_")
 }
 
-// Scan a row into a model
-func Scan(model Model, row *sql.Rows, exclude []string) error {
-	// Validate kind
-	fields, err := modelFields(model, exclude)
+// Scan a row into a model.
+// The columns of the row are matched to the fields of the model by name,
+// columns which are not part of the model are discarded.
+// ENCRYPTED fields need the keys of a database, use Database.Scan for models with ENCRYPTED fields.
+func Scan(model Model, row *sql.Rows, include []string) error {
+	return scan(nil, GetMeta(model), model, row, include)
+}
+
+// Scan a row into a model, with the naming strategy and the keys of the database. See Scan.
+func (db *Database) Scan(model Model, row *sql.Rows, include []string) error {
+	return scan(db, db.meta(model), model, row, include)
+}
+
+// See Scan.
+func scan(db *Database, meta *ModelMeta, model Model, row *sql.Rows, include []string) error {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return errors.New("model is not a pointer to struct")
+	}
+	columns, err := row.Columns()
 	if err != nil {
 		return err
 	}
-	return row.Scan(fields...)
+	fields, finish := scanTargets(db, meta, model, columns, include)
+	if err := row.Scan(fields...); err != nil {
+		return err
+	}
+	finish()
+	return nil
 }
 
-// Get the model fields to scan into
-func modelFields(model Model, include []string) ([]any, error) {
-	if len(include) == 0 {
-		include = Columns(model)
+// Scan a single row into a model.
+// The row must contain the columns of the model in order, see Columns.
+func scanRow(db *Database, meta *ModelMeta, model Model, row *sql.Row, include []string) error {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return errors.New("model is not a pointer to struct")
 	}
-	// Use reflection to get the columns from the model
-	typeof := reflect.TypeOf(model)
-	struct_fields := make([]any, 0)
-	if typeof.Kind() != reflect.Ptr {
-		return nil, errors.New("model is not a pointer to struct")
+	columns := make([]string, 0)
+	for _, column := range meta.columns() {
+		if len(include) == 0 || typeutils.Contains(include, column) {
+			columns = append(columns, column)
+		}
+	}
+	fields, finish := scanTargets(db, meta, model, columns, include)
+	if err := row.Scan(fields...); err != nil {
+		return err
 	}
-	typeof = typeof.Elem()
-	for i := 0; i < typeof.NumField(); i++ {
-		if !TagValid(typeof.Field(i)) {
+	finish()
+	return nil
+}
+
+// Get the destinations to scan the columns into.
+// Columns which are not part of the model, or not included, are discarded.
+// The returned function must be called after scanning, to set the belongs-to relations.
+func scanTargets(db *Database, meta *ModelMeta, model Model, columns []string, include []string) ([]any, func()) {
+	value := reflect.ValueOf(model).Elem()
+	fields := make([]any, len(columns))
+	finishers := []func(){}
+	for i, column := range columns {
+		f, ok := meta.Field(column)
+		if !ok || (f.Related && !f.ForeignKey) || (len(include) > 0 && !typeutils.Contains(include, f.Column)) {
+			fields[i] = new(any)
 			continue
-		} else if isRelated(typeof.Field(i)) {
+		}
+		if f.ForeignKey {
+			var finish func()
+			fields[i], finish = foreignKeyDest(f, value)
+			finishers = append(finishers, finish)
 			continue
 		}
-		if typeutils.Contains(include, strings.ToLower(typeof.Field(i).Name)) {
-			struct_fields = append(struct_fields, reflect.ValueOf(model).Elem().Field(i).Addr().Interface())
+		fields[i] = scanDest(db, f, value)
+	}
+	return fields, func() {
+		for _, fn := range finishers {
+			fn()
+		}
+	}
+}
+
+// Get the destinations to scan the columns into, allowing NULL values for all fields.
+// Used for models loaded through a LEFT JOIN, where all columns are NULL if there is no related row.
+// The returned function must be called after scanning, it reports if the primary key was not NULL.
+func scanNullableTargets(db *Database, meta *ModelMeta, model Model, columns []string) ([]any, func() bool) {
+	value := reflect.ValueOf(model).Elem()
+	fields := make([]any, len(columns))
+	finishers := []func(){}
+	found := false
+	for i, column := range columns {
+		f, ok := meta.Field(column)
+		switch {
+		case !ok || (f.Related && !f.ForeignKey):
+			fields[i] = new(any)
+		case f.ForeignKey:
+			var finish func()
+			fields[i], finish = foreignKeyDest(f, value)
+			finishers = append(finishers, finish)
+		case f.Tags.Encrypted() || f.Tags.JSON():
+			fields[i] = scanDest(db, f, value)
+		default:
+			var dest = reflect.New(reflect.PtrTo(f.Type))
+			var primary = f == meta.Primary || (meta.Primary == nil && f.Column == "id")
+			fields[i] = dest.Interface()
+			finishers = append(finishers, func() {
+				if dest.Elem().IsNil() {
+					return
+				}
+				f.value(value).Set(dest.Elem().Elem())
+				if primary {
+					found = true
+				}
+			})
+		}
+	}
+	return fields, func() bool {
+		for _, fn := range finishers {
+			fn()
+		}
+		return found
+	}
+}
+
+// Get the destination to scan a foreign key column into.
+// The column is scanned into a pointer of the related primary key type,
+// to let database/sql handle the conversion and NULL values.
+// The returned function sets the belongs-to relation after scanning.
+func foreignKeyDest(f *FieldMeta, model reflect.Value) (any, func()) {
+	var keyType = reflect.TypeOf((*any)(nil)).Elem()
+	if related, err := f.RelatedMeta(); err == nil && related.Primary != nil {
+		keyType = related.Primary.Type
+	}
+	var key = reflect.New(reflect.PtrTo(keyType))
+	return key.Interface(), func() {
+		if key.Elem().IsNil() {
+			setForeignKey(f, model, nil)
+		} else {
+			setForeignKey(f, model, key.Elem().Elem().Interface())
 		}
 	}
-	return struct_fields, nil
+}
+
+// Get the destination to scan a column into.
+func scanDest(db *Database, f *FieldMeta, model reflect.Value) any {
+	switch {
+	case f.Tags.Encrypted():
+		return &encryptedScanner{dest: f.value(model), db: db}
+	case f.Tags.JSON():
+		return &jsonScanner{dest: f.value(model).Addr().Interface()}
+	}
+	return f.value(model).Addr().Interface()
 }
@@ -1,6 +1,10 @@
 package simpledb
 
-import "strings"
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
 
 // Generic filter for a queryset.
 type Filter struct {
@@ -59,6 +63,77 @@ func (f Filters) Add(column string, op string, value any) Filters {
 	return f
 }
 
+// Resolve the filter columns to the column names of the model.
+// This allows filtering on struct field names, as well as column names.
+// Lookups on JSON fields are also resolved. (settings__theme -> JSON_EXTRACT(settings, '$.theme'))
+// Equality lookups on ENCRYPTED fields with a blind index are done on the blind index,
+// with the keys of the database.
+func (f Filters) Resolve(db *Database, model Model) (Filters, error) {
+	return f.resolve(db, db.meta(model), "")
+}
+
+// See Resolve.
+// The columns of the model are qualified with the table if it is not empty,
+// needed when other tables are joined. Qualified columns and expressions are left as is.
+func (f Filters) resolve(db *Database, meta *ModelMeta, table string) (Filters, error) {
+	resolved := make(Filters, len(f))
+	for i, filter := range f {
+		if strings.ContainsAny(filter.Column, ".(") {
+			resolved[i] = filter
+			continue
+		}
+		column := meta.ColumnName(filter.Column)
+		value := filter.Value
+		field, ok := meta.Field(filter.Column)
+		if lookup, is_json := jsonLookup(meta, table, filter.Column); is_json {
+			column = lookup
+		} else if ok && field.Tags.Encrypted() {
+			var err error
+			column, value, err = blindIndexFilter(db, field, filter)
+			if err != nil {
+				return nil, err
+			}
+			column = qualified(table, column)
+		} else if ok {
+			column = qualified(table, column)
+		}
+		resolved[i] = &Filter{Column: column, Value: value, Operator: filter.Operator}
+	}
+	return resolved, nil
+}
+
+// Convert a filter on an encrypted field to a filter on its blind index.
+// Returns an error if the field has no blind index, or the operator is not supported.
+// IN lookups accept any slice of values.
+func blindIndexFilter(db *Database, field *FieldMeta, filter *Filter) (string, any, error) {
+	if field.Tags.BlindIndex() == "" {
+		return "", nil, errors.New("cannot filter on encrypted field " + field.Column + " without a blind index")
+	}
+	switch strings.ToUpper(filter.Operator) {
+	case EQ, NE:
+		index, err := db.BlindIndex(filter.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		return field.Tags.BlindIndex(), index, nil
+	case IN:
+		values := reflect.ValueOf(filter.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return "", nil, errors.New("IN lookup on encrypted field " + field.Column + " needs a slice of values")
+		}
+		indexes := make([]any, values.Len())
+		for i := range indexes {
+			index, err := db.BlindIndex(values.Index(i).Interface())
+			if err != nil {
+				return "", nil, err
+			}
+			indexes[i] = index
+		}
+		return field.Tags.BlindIndex(), indexes, nil
+	}
+	return "", nil, errors.New("only equality lookups are supported on encrypted field " + field.Column)
+}
+
 // Get a filter from the list of filters.
 func (f Filters) Get(column string) any {
 	for _, filter := range f {
@@ -0,0 +1,127 @@
+package simpledb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// GenericRelation points to a model of any registered type.
+// It is stored in a content_table and an object_id column, both are NULL if the relation is not set.
+// Embed it into a model, or use a named field with the EMBED and PREFIX tags for multiple generic relations.
+// Example:
+//
+//	type Comment struct {
+//	    ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+//	    Body string `simpledb:"LENGTH:1024"`
+//	    simpledb.GenericRelation                    // content_table, object_id
+//	    Author simpledb.GenericRelation `simpledb:"EMBED:true,PREFIX:author_"` // author_content_table, author_object_id
+//	}
+type GenericRelation struct {
+	ContentTable sql.NullString `simpledb:"COLUMN:content_table,LENGTH:64,NULLABLE:true"`
+	ObjectID     sql.NullString `simpledb:"COLUMN:object_id,LENGTH:64,NULLABLE:true"`
+}
+
+// Point the relation to a model.
+// A nil model clears the relation, the columns are stored as NULL.
+func (g *GenericRelation) Set(target Model) {
+	if target == nil || reflect.ValueOf(target).IsNil() {
+		g.ContentTable, g.ObjectID = sql.NullString{}, sql.NullString{}
+		return
+	}
+	_, pk := PrimaryKey(target)
+	g.ContentTable = sql.NullString{String: target.TableName(), Valid: true}
+	g.ObjectID = sql.NullString{String: genericKey(pk), Valid: true}
+}
+
+// Check if the relation points to a model.
+func (g GenericRelation) IsSet() bool {
+	return g.ContentTable.Valid && g.ObjectID.Valid
+}
+
+// Convert a primary key to the string stored in the object_id column.
+// Binary keys are hex encoded.
+func genericKey(pk any) string {
+	if b, ok := pk.([]byte); ok {
+		return hex.EncodeToString(b)
+	}
+	return fmt.Sprint(pk)
+}
+
+// Get the columns of a generic relation of a model.
+// The name is the name of the struct field holding the relation,
+// it can be omitted if the model has a single generic relation.
+func genericColumns(meta *ModelMeta, name ...string) (table_column, id_column string, err error) {
+	var found []int
+	for i := 0; i < meta.Type.NumField(); i++ {
+		f := meta.Type.Field(i)
+		if f.Type != reflect.TypeOf(GenericRelation{}) || (len(name) > 0 && f.Name != name[0]) {
+			continue
+		}
+		if found != nil {
+			return "", "", errors.New("model " + meta.Table + " has multiple generic relations, provide the name of the field")
+		}
+		found = f.Index
+	}
+	if found == nil {
+		return "", "", errors.New("no generic relation on model " + meta.Table)
+	}
+	for _, f := range meta.Columns {
+		if len(f.Index) != len(found)+1 || !reflect.DeepEqual(f.Index[:len(found)], found) {
+			continue
+		}
+		switch f.Name {
+		case "ContentTable":
+			table_column = f.Column
+		case "ObjectID":
+			id_column = f.Column
+		}
+	}
+	if table_column == "" || id_column == "" {
+		return "", "", errors.New("generic relation of model " + meta.Table + " is not flattened, use the EMBED tag")
+	}
+	return table_column, id_column, nil
+}
+
+// Get the models with a generic relation pointing to a target as a QuerySet.
+// If the primary key of the target is not set, all models pointing to the table of the target are selected.
+// Example:
+//
+//	qs, err := db.GenericRelated(ctx, &Comment{}, post)
+//	comments := qs.OrderBy("id", "DESC").MultiModel()
+func (db *Database) GenericRelated(ctx context.Context, model Model, target Model, name ...string) (*QuerySet, error) {
+	table_column, id_column, err := genericColumns(db.meta(model), name...)
+	if err != nil {
+		return nil, err
+	}
+	qs := NewQuerySet(db, NewModel(model)).WithContext(ctx).All().Where(table_column, EQ, target.TableName())
+	if _, pk := primaryKey(db.meta(target), target); pk != nil && !reflect.ValueOf(pk).IsZero() {
+		qs.Where(id_column, EQ, genericKey(pk))
+	}
+	return qs, nil
+}
+
+// Get the model a generic relation points to.
+// The model type is looked up in the registered models of the database.
+func (db *Database) ResolveGeneric(ctx context.Context, g GenericRelation) (Model, error) {
+	if !g.IsSet() {
+		return nil, errors.New("generic relation is not set")
+	}
+	model, ok := db.ModelByTable(g.ContentTable.String)
+	if !ok {
+		return nil, errors.New("model " + g.ContentTable.String + " is not registered")
+	}
+	meta := db.meta(model)
+	var key any = g.ObjectID.String
+	if meta.Primary != nil && meta.Primary.Type == reflect.TypeOf([]byte{}) {
+		b, err := hex.DecodeString(g.ObjectID.String)
+		if err != nil {
+			return nil, err
+		}
+		key = b
+	}
+	return NewQuerySet(db, NewModel(model)).WithContext(ctx).All().Where(meta.PrimaryColumn(), EQ, key).SingleModel()
+}
@@ -1,10 +1,14 @@
 package simpledb
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Nigel2392/typeutils"
 	"github.com/joho/godotenv"
@@ -16,11 +20,34 @@ func (db *Database) Addr() string {
 }
 
 // Get the DSN for the database
+// The SSL_MODE is set as the tls parameter, see sslModes.
 func (db *Database) DSN() string {
+	var params = "parseTime=true"
+	if tls, _, err := db.sslModes(); err == nil && tls != "false" {
+		params += "&tls=" + tls
+	}
 	if db.Password == "" {
-		return fmt.Sprintf("%s@tcp(%s)/%s?parseTime=true", db.Username, db.Addr(), db.Database)
+		return fmt.Sprintf("%s@tcp(%s)/%s?%s", db.Username, db.Addr(), db.Database, params)
 	}
-	return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", db.Username, db.Password, db.Addr(), db.Database)
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", db.Username, db.Password, db.Addr(), db.Database, params)
+}
+
+// Get the tls parameter of the MySQL driver, and the --ssl-mode of the mysql client for the SSL_MODE of the database.
+// The driver verifies the host name of the server for both verify-ca and verify-full.
+func (db *Database) sslModes() (string, string, error) {
+	switch strings.ToLower(db.SSL_MODE) {
+	case "", "disable":
+		return "false", "DISABLED", nil
+	case "prefer":
+		return "preferred", "PREFERRED", nil
+	case "require":
+		return "skip-verify", "REQUIRED", nil
+	case "verify-ca":
+		return "true", "VERIFY_CA", nil
+	case "verify-full":
+		return "true", "VERIFY_IDENTITY", nil
+	}
+	return "", "", errors.New("unknown ssl mode " + db.SSL_MODE + ", use disable, prefer, require, verify-ca or verify-full")
 }
 
 // Verify if database has enough credentials to initialize a connections.
@@ -109,18 +136,65 @@ func NewModel(model Model) Model {
 }
 
 // Do some setup before looping over the model fields.
-func inlineLoopFields(kind reflect.Type, callback func(f reflect.StructField, i int)) {
+// Embedded structs are flattened into the fields of the model.
+// The callback receives the full index of the field (See reflect.Value.FieldByIndex),
+// and the column prefix of the struct the field was embedded from.
+func inlineLoopFields(kind reflect.Type, callback func(f reflect.StructField, index []int, prefix string)) {
+	loopFields(kind, nil, "", callback)
+}
+
+// Loop over the fields of a (possibly embedded) struct.
+func loopFields(kind reflect.Type, parent []int, prefix string, callback func(f reflect.StructField, index []int, prefix string)) {
 	// Loop through all fields in the struct
 	for i := 0; i < kind.NumField(); i++ {
 		// Get the current field
 		f := kind.Field(i)
+		index := make([]int, len(parent)+1)
+		copy(index, parent)
+		index[len(parent)] = i
+		if isEmbeddedPointer(f) {
+			panic("embedded struct pointer " + f.Name + " on " + kind.Name() + " is not supported, embed " + f.Type.Elem().Name() + " by value")
+		}
+		if isEmbedded(f) {
+			var p string
+			if f.Tag.Get(TAG) != "" {
+				p = TagMap(f).Prefix()
+			}
+			loopFields(f.Type, index, prefix+p, callback)
+			continue
+		}
 		if !TagValid(f) {
 			continue
 		}
-		callback(f, i)
+		callback(f, index, prefix)
 	}
 }
 
+// Check if a field is a struct which should be flattened into the model.
+// Anonymous structs are always flattened, named structs need the EMBED tag.
+// Pointers to structs cannot be flattened, they are rejected when the metadata is computed.
+func isEmbedded(f reflect.StructField) bool {
+	if f.Type.Kind() != reflect.Struct || f.Tag.Get(TAG) == "-" {
+		return false
+	}
+	if f.Type == reflect.TypeOf(time.Time{}) || reflect.PtrTo(f.Type).Implements(reflect.TypeOf((*sql.Scanner)(nil)).Elem()) {
+		return false
+	}
+	if f.Anonymous {
+		return true
+	}
+	return f.IsExported() && f.Tag.Get(TAG) != "" && TagMap(f).Embed()
+}
+
+// Check if a field is a pointer to a struct which would be flattened into the model, such as *Base.
+func isEmbeddedPointer(f reflect.StructField) bool {
+	if f.Type.Kind() != reflect.Ptr {
+		return false
+	}
+	f.Type = f.Type.Elem()
+	return isEmbedded(f)
+}
+
 // Get the kind of the model (Reflect.TYPE)
 func modelKind(model any) reflect.Type {
 	// Validate kind
@@ -137,8 +211,13 @@ func modelKind(model any) reflect.Type {
 // Convert a model to a table.
 // Used for migrations
 func ModelToTable(model Model) Table {
+	return modelToTable(GetMeta(model), model)
+}
+
+// See ModelToTable.
+func modelToTable(meta *ModelMeta, model Model) Table {
 	table := Table{Name: model.TableName(), Columns: []Column{}}
-	table.Columns = MigrationColumns(model)
-	table.Relations = MigrationRelations(model)
+	table.Columns = migrationColumns(meta, model)
+	table.Relations = migrationRelations(meta, model)
 	return table
 }
@@ -0,0 +1,427 @@
+package simpledb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the table the applied migrations are recorded in.
+const MigrationTable = "simpledb_migrations"
+
+// Name of the lock held while migrations are applied or rolled back. (GET_LOCK)
+const migrationLock = "simpledb_migrations"
+
+// Time to wait for another process to finish migrating the database.
+var MigrationLockTimeout = time.Minute
+
+// AppliedMigration is a migration which was applied to the database.
+type AppliedMigration struct {
+	ID int64 `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	// Name of the migration file, without the extension
+	Name string `simpledb:"LENGTH:255,UNIQUE:true"`
+	// SHA-256 checksum of the migration file when it was applied
+	Checksum  string    `simpledb:"LENGTH:64"`
+	AppliedAt time.Time `simpledb:"COLUMN:applied_at"`
+	// Time it took to apply the migration
+	Duration time.Duration
+}
+
+func (m *AppliedMigration) TableName() string {
+	return MigrationTable
+}
+
+// MigrationFile is a migration read from the migrations directory.
+type MigrationFile struct {
+	// Name of the file, without the extension.
+	// Names sort in the order the migrations were made, and are used as the version of the migration.
+	Name string
+	// SHA-256 checksum of the file
+	Checksum string
+	// The migration stored in the file
+	Migration Migration
+}
+
+// Read all migration files from the migrations directory, ordered by name.
+// The directory is created if it does not exist.
+func (m Migration) ReadMigrations() ([]MigrationFile, error) {
+	entries, err := os.ReadDir(m.Directory)
+	if err != nil {
+		err = os.Mkdir(m.Directory, 0755)
+		if err != nil {
+			return nil, errors.New("could not create migrations folder")
+		}
+	}
+	files := []MigrationFile{}
+	for _, f := range entries {
+		if f.IsDir() || !strings.HasPrefix(f.Name(), "Migration_") || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ".json")
+		if _, err := time.Parse("2006-01-02-15-04-05", strings.TrimPrefix(name, "Migration_")); err != nil {
+			return nil, errors.New("failed to parse migration file name: " + f.Name())
+		}
+		data, err := os.ReadFile(filepath.Join(m.Directory, f.Name()))
+		if err != nil {
+			return nil, errors.New("failed to read migration " + name + ": " + err.Error())
+		}
+		var migration Migration
+		if err := json.Unmarshal(data, &migration); err != nil {
+			return nil, errors.New("failed to parse migration " + name + ": " + err.Error())
+		}
+		migration.Database = m.Database
+		migration.Directory = m.Directory
+		sum := sha256.Sum256(data)
+		files = append(files, MigrationFile{Name: name, Checksum: hex.EncodeToString(sum[:]), Migration: migration})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// Create the table the applied migrations are recorded in, if it does not exist.
+func (db *Database) createMigrationTable(ctx context.Context) error {
+	query := strings.Replace(modelToTable(db.meta(&AppliedMigration{}), &AppliedMigration{}).String(), "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// Get the migrations which were applied to the database, ordered by name.
+func (db *Database) AppliedMigrations(ctx context.Context) ([]*AppliedMigration, error) {
+	if err := db.createMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, `SELECT * FROM `+MigrationTable+` ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := []*AppliedMigration{}
+	for _, model := range scanRows(ctx, db, db.meta(&AppliedMigration{}), rows, &AppliedMigration{}, nil) {
+		applied = append(applied, model.(*AppliedMigration))
+	}
+	return applied, nil
+}
+
+// Get the tables in the database, with the names of their columns.
+func (db *Database) schemaColumns(ctx context.Context) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	schema := map[string][]string{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		schema[table] = append(schema[table], column)
+	}
+	return schema, rows.Err()
+}
+
+// Check if the tables and columns of the migration exist in a schema, mapping table names to their columns.
+// The join tables of one to one and many to many relations have to exist as well.
+// A migration without tables never exists.
+func (m Migration) ExistsIn(schema map[string][]string) bool {
+	if len(m.Tables) == 0 {
+		return false
+	}
+	for _, t := range m.Tables {
+		columns, ok := schema[t.Name]
+		if !ok {
+			return false
+		}
+		existing := map[string]bool{}
+		for _, c := range columns {
+			existing[c] = true
+		}
+		for _, c := range t.Columns {
+			if !existing[c.Name] {
+				return false
+			}
+		}
+		for _, r := range t.Relations {
+			switch relationType(string(r.Type)) {
+			case relManyToMany, relOneToOne:
+				if _, ok := schema[r.joinTable()]; !ok {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Get the migration files which already exist in a schema, in order until the first one which is missing tables or columns.
+func baselineFiles(files []MigrationFile, schema map[string][]string) []MigrationFile {
+	for i, f := range files {
+		if !f.Migration.ExistsIn(schema) {
+			return files[:i]
+		}
+	}
+	return files
+}
+
+// Record the migration files which already exist in the database as applied, without running them.
+// This is done when the simpledb_migrations table is created in a database which was migrated before it existed.
+// Returns the recorded migrations.
+func (m Migration) baseline(ctx context.Context, files []MigrationFile, schema map[string][]string) ([]*AppliedMigration, error) {
+	applied := []*AppliedMigration{}
+	for _, f := range baselineFiles(files, schema) {
+		m.Database.Logger.Info("MIGRATION: ", f.Name, " already exists in the database, recording it as applied")
+		a := &AppliedMigration{
+			Name:      f.Name,
+			Checksum:  f.Checksum,
+			AppliedAt: m.Database.Now(),
+		}
+		if err := m.Database.InsertModelContext(ctx, a); err != nil {
+			return applied, errors.New("failed to record migration " + f.Name + ": " + err.Error())
+		}
+		applied = append(applied, a)
+	}
+	return applied, nil
+}
+
+// Take the migration lock, so only one process migrates the database at a time.
+// The lock belongs to a connection, which is kept until the lock is released with the returned function.
+func (db *Database) lockMigrations(ctx context.Context) (func(), error) {
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLock, int(MigrationLockTimeout.Seconds())).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, errors.New("failed to take the migration lock: " + err.Error())
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, errors.New("timed out waiting for the migration lock, another process is migrating the database")
+	}
+	return func() {
+		var released sql.NullInt64
+		if err := conn.QueryRowContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLock).Scan(&released); err != nil {
+			db.Logger.Warning("MIGRATION: failed to release the migration lock: ", err.Error())
+			// Discard the connection, the lock is released when its session ends.
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, nil
+}
+
+// Get the names of the migration files which are applied to the database, without changing the database.
+// If the simpledb_migrations table does not exist yet, these are the files Apply would record as applied.
+func (m Migration) appliedNames(ctx context.Context, files []MigrationFile) (map[string]bool, error) {
+	schema, err := m.Database.schemaColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	if _, ok := schema[MigrationTable]; !ok {
+		for _, f := range baselineFiles(files, schema) {
+			names[f.Name] = true
+		}
+		return names, nil
+	}
+	rows, err := m.Database.QueryContext(ctx, `SELECT name FROM `+MigrationTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+// Apply all migration files which were not applied to the database yet, in order.
+// Every migration is applied by comparing it to the migration file before it,
+// and recorded in the simpledb_migrations table afterwards.
+//
+// If the simpledb_migrations table does not exist yet, the files whose tables and columns
+// already exist in the database are recorded as applied without running them,
+// so databases migrated before the history was kept can be upgraded.
+//
+// The migration lock is held while the history is read and the migrations are applied,
+// processes migrating the same database wait for each other. (See MigrationLockTimeout)
+// Returns the number of migrations applied.
+func (m Migration) Apply(ctx context.Context) (int, error) {
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	unlock, err := m.Database.lockMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	schema, err := m.Database.schemaColumns(ctx)
+	if err != nil {
+		return 0, err
+	}
+	applied, err := m.Database.AppliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := schema[MigrationTable]; !ok {
+		baseline, err := m.baseline(ctx, files, schema)
+		if err != nil {
+			return 0, err
+		}
+		applied = append(applied, baseline...)
+	}
+	checksums := map[string]string{}
+	for _, a := range applied {
+		checksums[a.Name] = a.Checksum
+	}
+	var count int
+	var previous Migration
+	for _, f := range files {
+		if checksum, ok := checksums[f.Name]; ok {
+			if checksum != f.Checksum {
+				m.Database.Logger.Warning("MIGRATION: ", f.Name, " was changed after it was applied")
+			}
+			previous = f.Migration
+			continue
+		}
+		m.Database.Logger.Info("MIGRATION: applying ", f.Name)
+		start := time.Now()
+		if _, err := f.Migration.execute(ctx, f.Migration.operations(previous)); err != nil {
+			return count, errors.New("failed to apply migration " + f.Name + ": " + err.Error())
+		}
+		err := m.Database.InsertModelContext(ctx, &AppliedMigration{
+			Name:      f.Name,
+			Checksum:  f.Checksum,
+			AppliedAt: m.Database.Now(),
+			Duration:  time.Since(start),
+		})
+		if err != nil {
+			return count, errors.New("failed to record migration " + f.Name + ": " + err.Error())
+		}
+		previous = f.Migration
+		count++
+	}
+	if len(files) > 0 {
+		latest := files[len(files)-1].Migration
+		m.Database.LatestMigration = &latest
+	}
+	return count, nil
+}
+
+// Get the operations of a migration.
+// Migration files written before operations were recorded are compared to the previous migration.
+func (m Migration) operations(previous Migration) []Operation {
+	if len(m.Operations) > 0 {
+		return m.Operations
+	}
+	return m.Diff(previous)
+}
+
+// Roll back the migrations applied after a version, newest first.
+// The version is the name of a migration file, an empty version rolls back all migrations.
+// See Migration.Rollback.
+func (db *Database) Rollback(ctx context.Context, toVersion string) error {
+	_, err := NewMigration(db).Rollback(ctx, toVersion)
+	return err
+}
+
+// Roll back the migrations applied after a version, newest first.
+// Nothing is rolled back if any of the migrations cannot be undone.
+// The operations of every migration are undone in reverse order,
+// and the migration is removed from the simpledb_migrations table.
+// Operations which cannot restore the data they removed are logged as a warning before they are undone.
+// The migration lock is held while rolling back, see Migration.Apply.
+// Returns the number of migrations rolled back.
+func (m Migration) Rollback(ctx context.Context, toVersion string) (int, error) {
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	unlock, err := m.Database.lockMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	applied, err := m.Database.AppliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	index := map[string]int{}
+	for i, f := range files {
+		index[f.Name] = i
+	}
+	if _, ok := index[toVersion]; toVersion != "" && !ok {
+		return 0, errors.New("unknown migration " + toVersion)
+	}
+	// Collect the migrations to roll back, and check that all of them can be undone before anything is executed.
+	type step struct {
+		name       string
+		operations []Operation
+	}
+	var steps []step
+	for i := len(applied) - 1; i >= 0; i-- {
+		a := applied[i]
+		if a.Name <= toVersion {
+			break
+		}
+		f_index, ok := index[a.Name]
+		if !ok {
+			return 0, errors.New("migration file " + a.Name + " not found, cannot roll back")
+		}
+		var previous Migration
+		if f_index > 0 {
+			previous = files[f_index-1].Migration
+		}
+		operations := files[f_index].Migration.operations(previous)
+		for _, op := range operations {
+			if len(op.Reverse) == 0 {
+				return 0, errors.New("cannot roll back migration " + a.Name + ": " + op.Description + " is not reversible")
+			}
+		}
+		steps = append(steps, step{name: a.Name, operations: operations})
+	}
+	var count int
+	for _, s := range steps {
+		m.Database.Logger.Info("MIGRATION: rolling back ", s.name)
+		for j := len(s.operations) - 1; j >= 0; j-- {
+			op := s.operations[j]
+			if op.LossyReverse {
+				m.Database.Logger.Warning("MIGRATION: undoing ", op.Description, " in ", s.name, " cannot be done without losing data")
+			}
+			for _, query := range op.Reverse {
+				if _, err := m.Database.ExecContext(ctx, m.Database.resolveForeignKey(ctx, query)); err != nil {
+					return count, errors.New("failed to undo " + op.Description + " in " + s.name + ": " + err.Error())
+				}
+			}
+		}
+		if _, err := m.Database.ExecContext(ctx, `DELETE FROM `+MigrationTable+` WHERE name = ?`, s.name); err != nil {
+			return count, errors.New("failed to remove migration " + s.name + " from the history: " + err.Error())
+		}
+		applied = applied[:len(applied)-1]
+		count++
+	}
+	m.Database.LatestMigration = nil
+	if len(applied) > 0 {
+		if i, ok := index[applied[len(applied)-1].Name]; ok {
+			latest := files[i].Migration
+			m.Database.LatestMigration = &latest
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,131 @@
+package simpledb
+
+import "context"
+
+// Models can implement any of the following interfaces
+// to hook into the lifecycle of a model.
+//
+// Returning an error from a Before hook aborts the operation.
+// When the operation is run inside of a transaction (See Database.Transaction),
+// any error returned by a hook will roll back the transaction.
+
+// Called before a model is inserted or updated.
+type BeforeSaver interface {
+	BeforeSave(ctx context.Context) error
+}
+
+// Called after a model is inserted or updated.
+type AfterSaver interface {
+	AfterSave(ctx context.Context) error
+}
+
+// Called before a model is inserted.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// Called after a model is inserted.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// Called before a model is updated.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// Called after a model is updated.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// Called before a model is deleted.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// Called after a model is deleted.
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// Called after a model is scanned from the database.
+type AfterFinder interface {
+	AfterFind(ctx context.Context) error
+}
+
+// Run the hooks before inserting a model.
+func beforeInsert(ctx context.Context, model Model) error {
+	if h, ok := model.(BeforeSaver); ok {
+		if err := h.BeforeSave(ctx); err != nil {
+			return err
+		}
+	}
+	if h, ok := model.(BeforeInserter); ok {
+		return h.BeforeInsert(ctx)
+	}
+	return nil
+}
+
+// Run the hooks after inserting a model.
+func afterInsert(ctx context.Context, model Model) error {
+	if h, ok := model.(AfterInserter); ok {
+		if err := h.AfterInsert(ctx); err != nil {
+			return err
+		}
+	}
+	if h, ok := model.(AfterSaver); ok {
+		return h.AfterSave(ctx)
+	}
+	return nil
+}
+
+// Run the hooks before updating a model.
+func beforeUpdate(ctx context.Context, model Model) error {
+	if h, ok := model.(BeforeSaver); ok {
+		if err := h.BeforeSave(ctx); err != nil {
+			return err
+		}
+	}
+	if h, ok := model.(BeforeUpdater); ok {
+		return h.BeforeUpdate(ctx)
+	}
+	return nil
+}
+
+// Run the hooks after updating a model.
+func afterUpdate(ctx context.Context, model Model) error {
+	if h, ok := model.(AfterUpdater); ok {
+		if err := h.AfterUpdate(ctx); err != nil {
+			return err
+		}
+	}
+	if h, ok := model.(AfterSaver); ok {
+		return h.AfterSave(ctx)
+	}
+	return nil
+}
+
+// Run the hooks before deleting a model.
+func beforeDelete(ctx context.Context, model Model) error {
+	if h, ok := model.(BeforeDeleter); ok {
+		return h.BeforeDelete(ctx)
+	}
+	return nil
+}
+
+// Run the hooks after deleting a model.
+func afterDelete(ctx context.Context, model Model) error {
+	if h, ok := model.(AfterDeleter); ok {
+		return h.AfterDelete(ctx)
+	}
+	return nil
+}
+
+// Run the hooks after scanning a model.
+func afterFind(ctx context.Context, model Model) error {
+	if h, ok := model.(AfterFinder); ok {
+		return h.AfterFind(ctx)
+	}
+	return nil
+}
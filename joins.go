@@ -0,0 +1,112 @@
+package simpledb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix of the aliases of joined tables, so they cannot clash with the names of tables.
+const joinAliasPrefix = `rel__`
+
+// Get the alias of a table joined by following relations, by the path of relation names. (rel__author__publisher)
+func joinAlias(path ...string) string {
+	return joinAliasPrefix + strings.Join(path, `__`)
+}
+
+// Get the JOIN clauses to follow a relation from a table, joining the related table with an alias.
+// Relations stored in a join table join the join table as well. (alias__link)
+// Reports if the relation can match more than one related row.
+func relationJoins(meta *ModelMeta, from string, f *FieldMeta, alias string) ([]string, bool) {
+	related, _ := f.RelatedMeta()
+	if f.ForeignKey {
+		return []string{fmt.Sprintf(`LEFT JOIN %s AS %s ON %s.%s = %s.%s`, related.Table, alias, alias, related.PrimaryColumn(), from, f.Column)}, false
+	}
+	if relationType(f.Tags.RelType()) == relOneToMany {
+		return []string{fmt.Sprintf(`LEFT JOIN %s AS %s ON %s.%s = %s.%s`, related.Table, alias, alias, reverseColumn(meta, f), from, meta.PrimaryColumn())}, true
+	}
+	table, from_column, to_column := joinTable(meta, f)
+	link := alias + `__link`
+	return []string{
+		fmt.Sprintf(`LEFT JOIN %s AS %s ON %s.%s = %s.%s`, table, link, link, from_column, from, meta.PrimaryColumn()),
+		fmt.Sprintf(`LEFT JOIN %s AS %s ON %s.%s = %s.%s`, related.Table, alias, alias, related.PrimaryColumn(), link, to_column),
+	}, relationType(f.Tags.RelType()) != relOneToOne
+}
+
+// Resolve a lookup which spans relations to a column of the related table.
+// The relations are followed by name, separated by double underscores, the rest is the column,
+// or a lookup on a JSON field of the related model.
+// The tables needed are joined to the query with an alias, see joinAlias.
+// To-many relations make the query select DISTINCT rows.
+// Reports false if the lookup does not start with a relation of the model.
+// An invalid relation further down the lookup is reported through QuerySet.Err.
+// Example:
+//
+//	author__name             -> rel__author.name
+//	author__publisher__name  -> rel__author__publisher.name
+//	author__settings__theme  -> JSON_EXTRACT(rel__author.settings, '$.theme')
+func (q *QuerySet) span(lookup string) (string, bool) {
+	parts := strings.Split(lookup, "__")
+	if q.Model == nil || len(parts) < 2 {
+		return lookup, false
+	}
+	meta := q.db.meta(q.Model)
+	if _, ok := meta.Relation(parts[0]); !ok {
+		return lookup, false
+	}
+	var alias, current = meta.Table, meta
+	var i int
+	for ; i < len(parts)-1; i++ {
+		f, ok := current.Relation(parts[i])
+		if !ok {
+			break
+		}
+		related, err := f.RelatedMeta()
+		if err != nil {
+			q.fail(err)
+			return lookup, false
+		}
+		next := joinAlias(parts[:i+1]...)
+		joins, many := relationJoins(current, alias, f, next)
+		q.addJoins(joins...)
+		q.distinct = q.distinct || many
+		alias, current = next, related
+	}
+	rest := strings.Join(parts[i:], "__")
+	if column, ok := jsonLookup(current, alias, rest); ok {
+		return column, true
+	}
+	if i < len(parts)-1 {
+		q.fail(errors.New("no relation " + parts[i] + " on model " + current.Table + " in lookup " + lookup))
+		return lookup, false
+	}
+	return alias + `.` + current.ColumnName(rest), true
+}
+
+// Add JOIN clauses to the query, clauses which were already added are skipped.
+func (q *QuerySet) addJoins(joins ...string) {
+	for _, join := range joins {
+		found := false
+		for _, j := range q.joins {
+			if j == join {
+				found = true
+				break
+			}
+		}
+		if !found {
+			q.joins = append(q.joins, join)
+		}
+	}
+}
+
+// Resolve the filters which span relations, see QuerySet.span.
+func (q *QuerySet) spanFilters(filters Filters) Filters {
+	spanned := make(Filters, len(filters))
+	for i, filter := range filters {
+		spanned[i] = filter
+		if column, ok := q.span(filter.Column); ok {
+			spanned[i] = &Filter{Column: column, Value: filter.Value, Operator: filter.Operator}
+		}
+	}
+	return spanned
+}
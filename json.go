@@ -0,0 +1,80 @@
+package simpledb
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Scanner which unmarshals a JSON column into a struct field.
+type jsonScanner struct {
+	dest any
+}
+
+// Unmarshal the JSON column into the destination.
+func (s *jsonScanner) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		var field = reflect.ValueOf(s.dest).Elem()
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("cannot scan JSON column from " + reflect.TypeOf(src).String())
+	}
+	return json.Unmarshal(data, s.dest)
+}
+
+// Marshal a JSON field to be stored in the database.
+// Nil slices, maps and pointers are stored as NULL if the column is nullable.
+func jsonValue(field reflect.Value, nullable bool) (any, error) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if field.IsNil() && nullable {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Convert a JSON lookup (settings__theme) to a JSON_EXTRACT(settings, '$.theme') expression.
+// The column is qualified with the table if it is not empty. (JSON_EXTRACT(user.settings, '$.theme'))
+// Returns false if the column is not a JSON lookup on a JSON field of the model.
+func jsonLookup(meta *ModelMeta, table string, column string) (string, bool) {
+	parts := strings.Split(column, "__")
+	if len(parts) < 2 {
+		return "", false
+	}
+	f, ok := meta.Field(parts[0])
+	if !ok || !f.Tags.JSON() {
+		return "", false
+	}
+	for _, part := range parts[1:] {
+		if !isIdentifier(part) {
+			return "", false
+		}
+	}
+	return "JSON_EXTRACT(" + qualified(table, f.Column) + ", '$." + strings.Join(parts[1:], ".") + "')", true
+}
+
+// Check if a string only contains letters, digits and underscores.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+package simpledb
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyGenerator generates a new primary key for a model.
+type KeyGenerator func() (any, error)
+
+// Registry of key generators, name -> KeyGenerator
+var keyGenerators sync.Map
+
+func init() {
+	RegisterKeyGenerator("uuid4", func() (any, error) { return NewUUIDv4() })
+	RegisterKeyGenerator("uuid7", func() (any, error) { return NewUUIDv7() })
+	RegisterKeyGenerator("ulid", func() (any, error) { return NewULID() })
+	RegisterKeyGenerator("snowflake", NewSnowflake(0).Generate)
+}
+
+// Register a key generator, which can be used with the GENERATE tag.
+// The builtin generators are: uuid4, uuid7, ulid and snowflake.
+// Example:
+//
+//	simpledb.RegisterKeyGenerator("snowflake", simpledb.NewSnowflake(1).Generate)
+//
+//	type Order struct {
+//	    ID int64 `simpledb:"PRIMARY:true,GENERATE:snowflake"`
+//	}
+func RegisterKeyGenerator(name string, fn KeyGenerator) {
+	keyGenerators.Store(strings.ToLower(name), fn)
+}
+
+// Get a key generator by name.
+func getKeyGenerator(name string) (KeyGenerator, bool) {
+	fn, ok := keyGenerators.Load(strings.ToLower(name))
+	if !ok {
+		return nil, false
+	}
+	return fn.(KeyGenerator), true
+}
+
+// Get the primary key column and value of a model.
+// Defaults to the id column if the model has no primary key.
+func PrimaryKey(model Model) (string, any) {
+	return primaryKey(GetMeta(model), model)
+}
+
+// See PrimaryKey.
+func primaryKey(meta *ModelMeta, model Model) (string, any) {
+	if meta.Primary == nil {
+		return "id", getValue(meta, model, "id")
+	}
+	return meta.Primary.Column, getValue(meta, model, meta.Primary.Column)
+}
+
+// Generate a primary key for the model, if the primary key has a GENERATE tag and is not set yet.
+func generateKey(meta *ModelMeta, model Model) error {
+	if meta.Primary == nil || meta.Primary.Tags.Generate() == "" {
+		return nil
+	}
+	field := meta.Primary.value(reflect.ValueOf(model).Elem())
+	if !field.IsZero() {
+		return nil
+	}
+	fn, ok := getKeyGenerator(meta.Primary.Tags.Generate())
+	if !ok {
+		return errors.New("unknown key generator: " + meta.Primary.Tags.Generate())
+	}
+	key, err := fn()
+	if err != nil {
+		return err
+	}
+	if uuid, ok := key.(UUIDValue); ok {
+		switch {
+		case field.Kind() == reflect.String:
+			key = uuid.String()
+		case field.Kind() == reflect.Slice:
+			key = uuid[:]
+		}
+	}
+	v := reflect.ValueOf(key)
+	if !v.Type().ConvertibleTo(field.Type()) {
+		return errors.New("cannot use generated key of type " + v.Type().String() + " for " + meta.Primary.Column)
+	}
+	field.Set(v.Convert(field.Type()))
+	return nil
+}
+
+// Check if the model's primary key is filled in by the database (AUTO_INCREMENT).
+// Returns true for integer primary keys which are not set, and have no GENERATE tag.
+func autoIncrementKey(meta *ModelMeta, model Model) bool {
+	if meta.Primary == nil {
+		return true
+	}
+	if meta.Primary.Tags.Generate() != "" {
+		return false
+	}
+	field := meta.Primary.value(reflect.ValueOf(model).Elem())
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.IsZero()
+	}
+	return false
+}
+
+// UUIDValue is a 128 bit universally unique identifier.
+// Stored as CHAR(36) in string fields, and as BINARY(16) in []byte fields.
+type UUIDValue [16]byte
+
+// String representation of the UUID. (xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx)
+func (u UUIDValue) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Generate a random (version 4) UUID.
+func NewUUIDv4() (UUIDValue, error) {
+	var u UUIDValue
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, err
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+// Generate a time ordered (version 7) UUID.
+func NewUUIDv7() (UUIDValue, error) {
+	var u UUIDValue
+	if _, err := rand.Read(u[6:]); err != nil {
+		return u, err
+	}
+	putMillis(u[:6], time.Now())
+	u[6] = (u[6] & 0x0f) | 0x70
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+// Crockford's base32 alphabet, used for ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Generate a time ordered ULID, encoded as 26 characters.
+func NewULID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	putMillis(u[:6], time.Now())
+	// Encode the 128 bits as 26 base32 characters, the first character holds 3 bits.
+	var out [26]byte
+	var hi = binary.BigEndian.Uint64(u[:8])
+	var lo = binary.BigEndian.Uint64(u[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = (lo >> 5) | (hi << 59)
+		hi >>= 5
+	}
+	return string(out[:]), nil
+}
+
+// Write the unix milliseconds of a time as 48 bits.
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+}
+
+// Epoch of snowflake IDs. (2020-01-01 00:00:00 UTC)
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates time ordered 64 bit IDs.
+// 41 bits of milliseconds since SnowflakeEpoch, 10 bits of node ID and 12 bits of sequence.
+type Snowflake struct {
+	mu       sync.Mutex
+	node     int64
+	last     int64
+	sequence int64
+}
+
+// Initialize a snowflake generator for a node. (0-1023)
+// Every application server should use a unique node ID.
+func NewSnowflake(node int64) *Snowflake {
+	return &Snowflake{node: node & 0x3ff}
+}
+
+// Generate a new snowflake ID.
+func (s *Snowflake) Generate() (any, error) {
+	return s.Next(), nil
+}
+
+// Get the next snowflake ID.
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Since(SnowflakeEpoch).Milliseconds()
+	if now <= s.last {
+		now = s.last
+		s.sequence = (s.sequence + 1) & 0xfff
+		if s.sequence == 0 {
+			// Sequence exhausted, wait for the next millisecond.
+			now++
+			time.Sleep(time.Until(SnowflakeEpoch.Add(time.Duration(now) * time.Millisecond)))
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+	return now<<22 | s.node<<12 | s.sequence
+}
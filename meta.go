@@ -0,0 +1,260 @@
+package simpledb
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// FieldMeta holds the parsed information of a single model field.
+type FieldMeta struct {
+	// Name of the struct field
+	Name string
+	// Name of the column in the database
+	Column string
+	// Index of the field, used with reflect.Value.FieldByIndex
+	Index []int
+	// Golang type of the field
+	Type reflect.Type
+	// Parsed tags of the field
+	Tags ModelTags
+	// Is the field a related field (Rel_)
+	Related bool
+	// Is the field a belongs-to relation, stored in a foreign key column.
+	// The column of the field is the foreign key column.
+	ForeignKey bool
+	// Naming strategy of the model, used for the metadata of the related model
+	naming NamingStrategy
+}
+
+// Check if the column of the field is nullable.
+// Pointers and sql.Null* types are nullable, unless the NULLABLE tag says otherwise.
+func (f *FieldMeta) nullable() bool {
+	if f.Tags.Has("NULLABLE") {
+		return f.Tags.Nullable()
+	}
+	_, nullable, _ := GoColumnType(f.Type)
+	return nullable
+}
+
+// Get the value of the field on a model struct.
+func (f *FieldMeta) value(model reflect.Value) reflect.Value {
+	return model.FieldByIndex(f.Index)
+}
+
+// ModelMeta holds the parsed information of a model type.
+// It is computed once per type, and cached for all later calls.
+type ModelMeta struct {
+	// Type of the model struct
+	Type reflect.Type
+	// Name of the table
+	Table string
+	// All valid fields of the model, including related fields
+	Fields []*FieldMeta
+	// Fields which map to a column in the table, excluding related fields
+	Columns []*FieldMeta
+	// Related fields (Rel_)
+	Relations []*FieldMeta
+	// Belongs-to relations, stored in a foreign key column on the table
+	ForeignKeys []*FieldMeta
+	// The primary key field, if any
+	Primary *FieldMeta
+	// Lookup for fields by lowercased column or field name
+	lookup map[string]*FieldMeta
+}
+
+// Cache of model metadata, metaKey -> *ModelMeta
+var metaCache sync.Map
+
+// Key of the metadata cache.
+// The column names of a model depend on the naming strategy.
+type metaKey struct {
+	kind   reflect.Type
+	naming any
+}
+
+// Key of a naming function, functions are not comparable.
+type namingFunc struct {
+	kind    reflect.Type
+	pointer uintptr
+}
+
+// Get a comparable key for a naming strategy.
+func namingKey(naming NamingStrategy) any {
+	v := reflect.ValueOf(naming)
+	switch {
+	case v.Kind() == reflect.Func:
+		return namingFunc{kind: v.Type(), pointer: v.Pointer()}
+	case !v.Type().Comparable():
+		return v.Type()
+	}
+	return naming
+}
+
+// Get the metadata for a model.
+// The metadata is computed on the first call, and cached afterwards.
+// The column names follow the DefaultNamingStrategy,
+// use Database.Meta for the column names of the naming strategy of a database.
+func GetMeta(model any) *ModelMeta {
+	return metaFor(modelKind(model), model, DefaultNamingStrategy)
+}
+
+// Get the metadata for a model, with the column names of the naming strategy of the database.
+// See GetMeta.
+func (db *Database) Meta(model any) *ModelMeta {
+	return db.meta(model)
+}
+
+// Get the metadata of a model, with the column names of the naming strategy of the database.
+func (db *Database) meta(model any) *ModelMeta {
+	if db == nil {
+		return GetMeta(model)
+	}
+	return metaFor(modelKind(model), model, db.namingStrategy())
+}
+
+// Get the naming strategy of a database, defaults to the DefaultNamingStrategy.
+func (db *Database) namingStrategy() NamingStrategy {
+	if db == nil || db.NamingStrategy == nil {
+		return DefaultNamingStrategy
+	}
+	return db.NamingStrategy
+}
+
+// Get the cached metadata of a model type for a naming strategy.
+func metaFor(kind reflect.Type, model any, naming NamingStrategy) *ModelMeta {
+	if naming == nil {
+		naming = DefaultNamingStrategy
+	}
+	key := metaKey{kind: kind, naming: namingKey(naming)}
+	if meta, ok := metaCache.Load(key); ok {
+		return meta.(*ModelMeta)
+	}
+	meta, _ := metaCache.LoadOrStore(key, newModelMeta(kind, model, naming))
+	return meta.(*ModelMeta)
+}
+
+// Compute the metadata for a model type.
+func newModelMeta(kind reflect.Type, model any, naming NamingStrategy) *ModelMeta {
+	if naming == nil {
+		naming = DefaultNamingStrategy
+	}
+	meta := &ModelMeta{
+		Type:   kind,
+		lookup: make(map[string]*FieldMeta),
+	}
+	if m, ok := model.(Model); ok {
+		meta.Table = m.TableName()
+	}
+	fields := []*FieldMeta{}
+	columns := map[string]int{}
+	inlineLoopFields(kind, func(f reflect.StructField, index []int, prefix string) {
+		tags := TagMap(f)
+		related := isRelated(f)
+		foreignKey := related && isBelongsTo(tags.RelType())
+		column := tags.Column()
+		if column == "" && foreignKey {
+			column = naming.ColumnName(strings.TrimPrefix(f.Name, "Rel_")) + "_id"
+		} else if column == "" {
+			column = naming.ColumnName(f.Name)
+		}
+		field := &FieldMeta{
+			Name:       f.Name,
+			Column:     prefix + column,
+			Index:      index,
+			Type:       f.Type,
+			Tags:       tags,
+			Related:    related,
+			ForeignKey: foreignKey,
+			naming:     naming,
+		}
+		// Fields of the outer struct shadow the fields of embedded structs.
+		key := strings.ToLower(field.Column)
+		if i, ok := columns[key]; ok {
+			if len(fields[i].Index) > len(field.Index) {
+				fields[i] = field
+			}
+			return
+		}
+		columns[key] = len(fields)
+		fields = append(fields, field)
+	})
+	for _, field := range fields {
+		meta.Fields = append(meta.Fields, field)
+		if field.Related {
+			meta.Relations = append(meta.Relations, field)
+			if field.ForeignKey {
+				meta.ForeignKeys = append(meta.ForeignKeys, field)
+			}
+		} else {
+			meta.Columns = append(meta.Columns, field)
+		}
+		meta.lookup[strings.ToLower(field.Column)] = field
+	}
+	for _, field := range fields {
+		if _, ok := meta.lookup[strings.ToLower(field.Name)]; !ok {
+			meta.lookup[strings.ToLower(field.Name)] = field
+		}
+	}
+	for _, f := range meta.Columns {
+		if f.Tags.Primary() || strings.Contains(strings.ToUpper(f.Tags.Raw()), "PRIMARY KEY") {
+			meta.Primary = f
+			break
+		}
+	}
+	if meta.Primary == nil {
+		if f, ok := meta.lookup["id"]; ok && !f.Related {
+			meta.Primary = f
+		}
+	}
+	return meta
+}
+
+// Get a field by its column or struct field name, case insensitive.
+func (m *ModelMeta) Field(name string) (*FieldMeta, bool) {
+	f, ok := m.lookup[strings.ToLower(name)]
+	return f, ok
+}
+
+// Get the column name for a column or struct field name.
+// If the name is not a field of the model, it is returned unchanged.
+func (m *ModelMeta) ColumnName(name string) string {
+	if f, ok := m.Field(name); ok {
+		return f.Column
+	}
+	return name
+}
+
+// Get the column of the primary key, defaults to "id".
+func (m *ModelMeta) PrimaryColumn() string {
+	if m.Primary == nil {
+		return "id"
+	}
+	return m.Primary.Column
+}
+
+// Get the column names of the model, including foreign key columns.
+func (m *ModelMeta) ColumnNames() []string {
+	columns := make([]string, 0, len(m.Columns)+len(m.ForeignKeys))
+	for _, f := range m.Columns {
+		columns = append(columns, f.Column)
+	}
+	for _, f := range m.ForeignKeys {
+		columns = append(columns, f.Column)
+	}
+	return columns
+}
+
+// Get the metadata of the model a related field points to.
+// The field must be a pointer to a struct, or a slice of (pointers to) structs.
+func (f *FieldMeta) RelatedMeta() (*ModelMeta, error) {
+	t := f.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("related field " + f.Name + " must be a pointer to a struct")
+	}
+	return metaFor(t, reflect.New(t).Interface(), f.naming), nil
+}
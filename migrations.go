@@ -1,10 +1,12 @@
 package simpledb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,28 +19,58 @@ type DBType string
 // Database types
 const (
 	VARCHAR     DBType = "VARCHAR"
+	CHAR        DBType = "CHAR"
+	TINYTEXT    DBType = "TINYTEXT"
 	TEXT        DBType = "TEXT"
+	MEDIUMTEXT  DBType = "MEDIUMTEXT"
+	LONGTEXT    DBType = "LONGTEXT"
 	INT         DBType = "INT"
 	BOOLEAN     DBType = "BOOLEAN"
 	TINYINT     DBType = "TINYINT"
 	SMALLINT    DBType = "SMALLINT"
+	MEDIUMINT   DBType = "MEDIUMINT"
 	BIGINT      DBType = "BIGINT"
 	FLOAT       DBType = "FLOAT"
 	DOUBLE      DBType = "DOUBLE"
+	DECIMAL     DBType = "DECIMAL"
+	DATE        DBType = "DATE"
+	TIME        DBType = "TIME"
 	DATETIME    DBType = "DATETIME"
+	TIMESTAMP   DBType = "TIMESTAMP"
+	BINARY      DBType = "BINARY"
+	VARBINARY   DBType = "VARBINARY"
 	BLOB        DBType = "BLOB"
+	MEDIUMBLOB  DBType = "MEDIUMBLOB"
+	LONGBLOB    DBType = "LONGBLOB"
+	JSON        DBType = "JSON"
+	ENUM        DBType = "ENUM"
+	SET         DBType = "SET"
 	FOREIGN_KEY DBType = "FOREIGN KEY"
 )
 
+// Pseudo types, which are converted to a real type when creating a column.
+const (
+	// Stored as CHAR(36)
+	UUID DBType = "UUID"
+	// Stored as BINARY(16)
+	UUID_BINARY DBType = "UUID_BINARY"
+)
+
 // Representation of a column,
 // used to create tables when migrating
 type Column struct {
-	Table    string
-	Name     string
-	Default  string
-	Type     DBType
-	Raw      string
-	Length   int
+	Table   string
+	Name    string
+	Default string
+	Type    DBType
+	Raw     string
+	Length  int
+	// Precision and scale of DECIMAL columns
+	Precision int
+	Scale     int
+	// Allowed values of ENUM and SET columns
+	Values   []string
+	Unsigned bool
 	Nullable bool
 	Unique   bool
 	Primary  bool
@@ -51,14 +83,10 @@ type Column struct {
 func (c Column) String() string {
 	var s string
 	s += c.Name + " "
-	s += string(c.Type)
 	if c.Raw != "" {
-		s += " " + c.Raw
-		return s
-	}
-	if c.Length > 0 {
-		s += "(" + strconv.Itoa(c.Length) + ")"
+		return s + c.typeString() + " " + c.Raw
 	}
+	s += c.typeString()
 	if c.Nullable {
 		s += " NULL"
 	} else {
@@ -82,6 +110,36 @@ func (c Column) String() string {
 	return s
 }
 
+// Get the type of the column, including the length and UNSIGNED. (VARCHAR(255), INT UNSIGNED)
+func (c Column) typeString() string {
+	s := string(c.Type)
+	if c.Raw != "" {
+		if c.Unsigned {
+			s += " UNSIGNED"
+		}
+		return s
+	}
+	switch {
+	case c.Type == DECIMAL && c.Precision > 0:
+		s += "(" + strconv.Itoa(c.Precision) + "," + strconv.Itoa(c.Scale) + ")"
+	case (c.Type == ENUM || c.Type == SET) && len(c.Values) > 0:
+		s += "("
+		for i, v := range c.Values {
+			s += "'" + strings.ReplaceAll(v, "'", "''") + "'"
+			if i < len(c.Values)-1 {
+				s += ","
+			}
+		}
+		s += ")"
+	case c.Length > 0:
+		s += "(" + strconv.Itoa(c.Length) + ")"
+	}
+	if c.Unsigned {
+		s += " UNSIGNED"
+	}
+	return s
+}
+
 // Table representation,
 // used to create tables when migrating
 type Table struct {
@@ -109,6 +167,114 @@ type Relation struct {
 	From string
 	To   string
 	Type DBType
+	// Name of the relation, if it differs from the To table.
+	// Used to name the join table, allowing multiple relations between the same tables.
+	Name string `json:",omitempty"`
+	// Foreign key column on the From table, for belongs-to relations
+	Column string `json:",omitempty"`
+	// Column on the To table the foreign key references,
+	// the primary key of the To table for relations stored in a join table.
+	References string `json:",omitempty"`
+	// Primary key of the From table, referenced by the join table of a relation.
+	FromReferences string `json:",omitempty"`
+	// Types of the columns of the join table, the types of the primary keys they reference.
+	FromType string `json:",omitempty"`
+	ToType   string `json:",omitempty"`
+	// Table of the through model of a many to many relation
+	Through string `json:",omitempty"`
+	// Referential action when the referenced row is deleted
+	OnDelete string `json:",omitempty"`
+	// Referential action when the referenced key is updated
+	OnUpdate string `json:",omitempty"`
+	// Definition of the foreign key column of a one to many relation, added to the To table.
+	Definition string `json:",omitempty"`
+	// The foreign key column of a one to many relation is declared by the model of the To table,
+	// only the constraint is added and dropped with the relation.
+	Declared bool `json:",omitempty"`
+}
+
+// Get the name of the relation, defaults to the To table.
+func (r Relation) name() string {
+	if r.Name == "" {
+		return r.To
+	}
+	return r.Name
+}
+
+// Get the columns of the From and To table referenced by the join table, default to id.
+func (r Relation) joinReferences() (string, string) {
+	from, to := r.FromReferences, r.References
+	if from == "" {
+		from = "id"
+	}
+	if to == "" {
+		to = "id"
+	}
+	return from, to
+}
+
+// Get the types of the columns of the join table, default to BIGINT.
+func (r Relation) joinTypes() (string, string) {
+	from, to := r.FromType, r.ToType
+	if from == "" {
+		from = string(BIGINT)
+	}
+	if to == "" {
+		to = string(BIGINT)
+	}
+	return from, to
+}
+
+// Check if dropping the relation removes data.
+// The join table of a many to many relation, and the foreign key column of a one to many relation are dropped.
+func (r Relation) dropsData() bool {
+	t := relationType(string(r.Type))
+	return t == relManyToMany || (t == relOneToMany && !r.Declared)
+}
+
+// Get the join table of a one to one or many to many relation. (from_name)
+func (r Relation) joinTable() string {
+	return r.From + "_" + r.name()
+}
+
+// Get the columns of the join table, pointing to the From and the To table.
+// The column pointing to the To table of a self-referential relation is named after the relation. (category_id, parent_id)
+func (r Relation) joinColumns() (string, string) {
+	from, to := r.From+"_id", r.To+"_id"
+	if from == to {
+		to = r.name() + "_id"
+		if to == from {
+			to = "to_" + to
+		}
+	}
+	return from, to
+}
+
+// Get the referential actions of the relation, appended to a FOREIGN KEY clause.
+func (r Relation) actions() string {
+	var actions string
+	if r.OnDelete != "" {
+		actions += " ON DELETE " + r.OnDelete
+	}
+	if r.OnUpdate != "" {
+		actions += " ON UPDATE " + r.OnUpdate
+	}
+	return actions
+}
+
+// Operation is a single change to the database made by a migration,
+// together with the queries to undo it.
+type Operation struct {
+	// Human readable description of the operation
+	Description string
+	// Queries to apply the operation
+	Forward []string
+	// Queries to undo the operation, in order
+	Reverse []string `json:",omitempty"`
+	// Applying the operation removes data from the database
+	Destructive bool `json:",omitempty"`
+	// Undoing the operation removes data, or cannot restore the data removed by applying it
+	LossyReverse bool `json:",omitempty"`
 }
 
 // Migration represents a set of changes to the database
@@ -117,6 +283,9 @@ type Migration struct {
 	Tables    []Table
 	Models    []Model
 	Directory string
+	// Operations to get from the previous migration to this migration.
+	// Recorded when the migration is made, used to apply and roll back the migration.
+	Operations []Operation `json:",omitempty"`
 }
 
 // Initialize a new migration
@@ -130,46 +299,68 @@ func NewMigration(db *Database) *Migration {
 // Create a migration from models.
 func (m *Migration) CreateFromModels(models []Model) {
 	for _, mdl := range models {
-		table := ModelToTable(mdl)
+		table := modelToTable(m.Database.meta(mdl), mdl)
 		m.Tables = append(m.Tables, table)
 	}
 }
 
+// Changes between two migrations, see Migration.Changes.
+type MigrationChanges struct {
+	MissingTables      []Table
+	MissingColumns     []Column
+	MissingRelations   []Relation
+	DifferentColumns   []Column
+	DifferentRelations []Relation
+	RemovedTables      []Table
+	RemovedColumns     []Column
+	RemovedRelations   []Relation
+}
+
+// Check if there are any changes.
+func (c MigrationChanges) Empty() bool {
+	return len(c.MissingTables)+len(c.MissingColumns)+len(c.MissingRelations)+len(c.DifferentColumns)+
+		len(c.DifferentRelations)+len(c.RemovedTables)+len(c.RemovedColumns)+len(c.RemovedRelations) == 0
+}
+
 // Validate a migration
 // This is used to make sure all fields are the same, if not, we can ALTER the fields on the database side.
+// Relations with different referential actions are only reported by Changes.
 func (m Migration) Validate(other Migration) ([]Table, []Column, []Relation, []Column, []Table, []Column, []Relation) {
+	c := m.Changes(other)
+	return c.MissingTables, c.MissingColumns, c.MissingRelations, c.DifferentColumns, c.RemovedTables, c.RemovedColumns, c.RemovedRelations
+}
+
+// Get the changes between the migration and another migration.
+func (m Migration) Changes(other Migration) MigrationChanges {
 	// Missing stuff
 	missing_tables, removed_tables := []Table{}, []Table{}
 	missing_columns, different_columns, removed_columns := []Column{}, []Column{}, []Column{}
-	missing_relations, removed_relations := []Relation{}, []Relation{}
-	// Check for missing tables
-	for t_index, t := range m.Tables {
-		if len(m.Tables) < len(other.Tables) {
-			found_table := false
-			for _, ot := range other.Tables {
-				for _, t := range m.Tables {
-					if t.Name == ot.Name {
-						found_table = true
-						break
-					}
-				}
-				if !found_table {
-					m.Database.Logger.Debug("MIGRATION: ", ot.Name, " was not found in the current migration, it will be removed")
-					removed_tables = append(removed_tables, ot)
-				}
-			}
-			if found_table {
-				continue
+	missing_relations, different_relations, removed_relations := []Relation{}, []Relation{}, []Relation{}
+	// Check for removed tables
+	for _, ot := range other.Tables {
+		found_table := false
+		for _, t := range m.Tables {
+			if t.Name == ot.Name {
+				found_table = true
+				break
 			}
 		}
-		has_table := false
-		for _, o := range other.Tables {
+		if !found_table {
+			m.Database.Logger.Debug("MIGRATION: ", ot.Name, " was not found in the current migration, it will be removed")
+			removed_tables = append(removed_tables, ot)
+		}
+	}
+	// Check for missing tables
+	for _, t := range m.Tables {
+		// Index of the table in the other migration, tables are matched by name.
+		t_index := -1
+		for i, o := range other.Tables {
 			if t.Name == o.Name {
-				has_table = true
+				t_index = i
 				break
 			}
 		}
-		if !has_table {
+		if t_index < 0 {
 			m.Database.Logger.Debug("MIGRATION: ", t.Name, " was missing, it will be added")
 			missing_tables = append(missing_tables, t)
 		} else {
@@ -221,8 +412,12 @@ func (m Migration) Validate(other Migration) ([]Table, []Column, []Relation, []C
 				for _, r := range t.Relations {
 					has_relation := false
 					for _, o_r := range other.Tables[t_index].Relations {
-						if r.From == o_r.From && r.To == o_r.To {
+						if r.From == o_r.From && r.To == o_r.To && r.name() == o_r.name() {
 							has_relation = true
+							if r.OnDelete != o_r.OnDelete || r.OnUpdate != o_r.OnUpdate {
+								m.Database.Logger.Debug("MIGRATION: ", r.From, " relation actions were different, they will be updated")
+								different_relations = append(different_relations, r)
+							}
 							break
 						}
 					}
@@ -236,7 +431,7 @@ func (m Migration) Validate(other Migration) ([]Table, []Column, []Relation, []C
 				for _, r := range other.Tables[t_index].Relations {
 					has_relation := false
 					for _, o_r := range t.Relations {
-						if r.From == o_r.From && r.To == o_r.To {
+						if r.From == o_r.From && r.To == o_r.To && r.name() == o_r.name() {
 							has_relation = true
 							break
 						}
@@ -249,206 +444,378 @@ func (m Migration) Validate(other Migration) ([]Table, []Column, []Relation, []C
 			}
 		}
 	}
-	return missing_tables, missing_columns, missing_relations, different_columns, removed_tables, removed_columns, removed_relations
+	return MigrationChanges{
+		MissingTables:      missing_tables,
+		MissingColumns:     missing_columns,
+		MissingRelations:   missing_relations,
+		DifferentColumns:   different_columns,
+		DifferentRelations: different_relations,
+		RemovedTables:      removed_tables,
+		RemovedColumns:     removed_columns,
+		RemovedRelations:   removed_relations,
+	}
 }
 
 // Execute a migration
+// A migration file is written if the models changed since the latest migration file,
+// then all migration files which were not applied yet are applied in order.
+// Applied migrations are recorded in the simpledb_migrations table.
 func (m Migration) Run() error {
-	// Get the latest migration
-	latest_migration, err := m.GetLatestMigration()
+	return m.RunContext(context.Background())
+}
+
+// See Run.
+func (m Migration) RunContext(ctx context.Context) error {
+	if _, err := m.Make(); err != nil {
+		return err
+	}
+	applied, err := m.Apply(ctx)
 	if err != nil {
 		return err
 	}
-	// Run the migrations when validating
-	missing_tables, missing_columns, missing_relations, different_columns, removed_tables, removed_columns, removed_relations := m.Validate(latest_migration)
+	if applied == 0 {
+		return errors.New("no migrations to run")
+	}
+	return nil
+}
+
+// Write a migration file if the models changed since the latest migration file.
+// Returns the name of the written migration, or an empty string if nothing changed.
+func (m Migration) Make() (string, error) {
+	latest_migration, err := m.GetLatestMigration()
+	if err != nil {
+		return "", err
+	}
+	if !m.Changed(latest_migration) {
+		return "", nil
+	}
+	m.Operations = m.Diff(latest_migration)
+	return m.write()
+}
+
+// Check if the migration differs from another migration.
+func (m Migration) Changed(other Migration) bool {
+	return !m.Changes(other).Empty()
+}
+
+// Get the operations to get from a previous migration to this migration.
+// Every operation holds the queries to apply it, and the queries to undo it.
+func (m Migration) Diff(previous Migration) []Operation {
+	changes := m.Changes(previous)
 	created := []string{}
-	var migrations int = 0
-	if len(missing_tables) > 0 {
-		// Create missing tables
-		for _, t := range missing_tables {
-			_, err := m.Database.Exec(t.String())
-			if err != nil {
-				return errors.New("failed to create table " + t.Name + ": " + err.Error())
-			} else {
-				created = append(created, t.Name)
+	operations := []Operation{}
+	// Create missing tables
+	for _, t := range changes.MissingTables {
+		created = append(created, t.Name)
+		operations = append(operations, Operation{
+			Description:  "create table " + t.Name,
+			Forward:      []string{t.String()},
+			Reverse:      []string{"DROP TABLE " + t.Name},
+			LossyReverse: true,
+		})
+	}
+	for _, t := range changes.MissingTables {
+		for _, r := range t.Relations {
+			if op, ok := createRelationOperation(r); ok {
+				operations = append(operations, op)
 			}
-			migrations++
 		}
-		for _, t := range missing_tables {
-			for _, r := range t.Relations {
-				err := m.Database.CreateFKTable(r.From, r.To)
-				if err != nil {
-					return errors.New("error creating relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				}
-				migrations++
-			}
+	}
+	// Add missing columns
+	for _, c := range changes.MissingColumns {
+		if typeutils.Contains(created, c.Table) {
+			continue
+		}
+		operations = append(operations, Operation{
+			Description:  "add column " + c.Name + " to table " + c.Table,
+			Forward:      []string{"ALTER TABLE " + c.Table + " ADD COLUMN " + c.String()},
+			Reverse:      []string{"ALTER TABLE " + c.Table + " DROP COLUMN " + c.Name},
+			LossyReverse: true,
+		})
+	}
+	// Create missing relations
+	for _, r := range changes.MissingRelations {
+		if op, ok := createRelationOperation(r); ok {
+			operations = append(operations, op)
+		}
+	}
+	// Update different columns
+	for _, c := range changes.DifferentColumns {
+		op := Operation{
+			Description: "update column " + c.Table + "." + c.Name,
+			Forward:     []string{"ALTER TABLE " + c.Table + " MODIFY COLUMN " + c.String()},
+			// Changing the type back can truncate values stored with the new definition.
+			LossyReverse: true,
+		}
+		if old, ok := previous.column(c.Table, c.Name); ok {
+			op.Reverse = []string{"ALTER TABLE " + c.Table + " MODIFY COLUMN " + old.String()}
+		}
+		operations = append(operations, op)
+	}
+	// Update the referential actions of different relations
+	for _, r := range changes.DifferentRelations {
+		if r.Through != "" {
+			continue
+		}
+		op := Operation{
+			Description: "update relation " + r.From + "." + r.name(),
+			Forward:     alterRelationQueries(r),
+		}
+		if old, ok := previous.relation(r); ok {
+			op.Reverse = alterRelationQueries(old)
+		}
+		operations = append(operations, op)
+	}
+	// Remove removed relations
+	// Relations are removed before the columns and tables, they cannot be dropped while constrained.
+	for _, r := range changes.RemovedRelations {
+		if op, ok := dropRelationOperation(r); ok {
+			operations = append(operations, op)
 		}
 	}
-	if len(missing_columns) > 0 {
-		// Add missing columns
-		for _, c := range missing_columns {
-			m.Database.Logger.Debug("MIGRATION: adding column ", c.Name, " to table", c.Table)
-			if typeutils.Contains(created, c.Table) {
+	// The join tables and foreign keys of other tables referencing a removed table are dropped with its relations.
+	// The foreign keys of belongs-to relations are dropped with the table itself.
+	for _, t := range changes.RemovedTables {
+		for _, r := range t.Relations {
+			if relationType(string(r.Type)) == relBelongsTo {
 				continue
 			}
-			_, err := m.Database.Exec("ALTER TABLE " + c.Table + " ADD COLUMN " + c.String())
-			if err != nil {
-				return errors.New("error adding column " + c.Name + " to table " + c.Table + ": " + err.Error())
+			if op, ok := dropRelationOperation(r); ok {
+				operations = append(operations, op)
 			}
-			migrations++
 		}
 	}
-	if len(missing_relations) > 0 {
-		// Create missing relations tables
-		for _, r := range missing_relations {
-			m.Database.Logger.Debug("MIGRATION: creating relation table for ", r.From, " and", r.To)
-			switch strings.ToLower(string(r.Type)) {
-			case "fk", "foreignkey":
-				err := m.Database.CreateFKTable(r.From, r.To)
-				if err != nil {
-					return errors.New("error creating relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				}
-			case "1t1", "onetoone":
-				err := m.Database.AlterOneToOne(r.From, r.To)
-				if err != nil {
-					return errors.New("error creating relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				}
-				//case "otm", "onetomany":
-				//	err := m.Database.AlterOneToMany(r.From, r.To)
-				//	if err != nil {
-				//		return errors.New("error creating relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				//	}
-			}
-			migrations++
-		}
+	// Remove removed columns
+	for _, c := range changes.RemovedColumns {
+		operations = append(operations, Operation{
+			Description:  "drop column " + c.Name + " from table " + c.Table,
+			Forward:      []string{"ALTER TABLE " + c.Table + " DROP COLUMN " + c.Name},
+			Reverse:      []string{"ALTER TABLE " + c.Table + " ADD COLUMN " + c.String()},
+			Destructive:  true,
+			LossyReverse: true,
+		})
 	}
-	if len(different_columns) > 0 {
-		// Update different columns
-		for _, c := range different_columns {
-			m.Database.Logger.Debug("MIGRATION: updating column ", c.Name, " in table", c.Table)
-			_, err := m.Database.Exec("ALTER TABLE " + c.Table + " MODIFY COLUMN " + c.String())
-			if err != nil {
-				return errors.New("error updating column " + c.Table + "." + c.Name + ": " + err.Error())
+	// Remove removed tables, after the relations referencing them.
+	for _, t := range changes.RemovedTables {
+		reverse := []string{t.String()}
+		for _, r := range t.Relations {
+			if relationType(string(r.Type)) == relBelongsTo {
+				reverse = append(reverse, addForeignKeyQuery(r))
 			}
-			migrations++
 		}
+		operations = append(operations, Operation{
+			Description:  "drop table " + t.Name,
+			Forward:      []string{"DROP TABLE " + t.Name},
+			Reverse:      reverse,
+			Destructive:  true,
+			LossyReverse: true,
+		})
+	}
+	return operations
+}
+
+// Get the operation to drop a relation.
+// Relations with a through model are skipped, the through model is migrated as a table of its own.
+func dropRelationOperation(r Relation) (Operation, bool) {
+	if r.Through != "" {
+		return Operation{}, false
+	}
+	reverse := createRelationQueries(r)
+	if relationType(string(r.Type)) == relOneToOne {
+		// Only the foreign key to the From table is dropped, see AlterDropOneToOne.
+		reverse = []string{addForeignKeyQuery(joinForeignKeys(r)[0])}
 	}
-	if len(removed_tables) > 0 {
-		// Remove removed tables
-		for _, t := range removed_tables {
-			m.Database.Logger.Debug("MIGRATION: removing table ", t.Name)
-			_, err := m.Database.Exec("DROP TABLE " + t.Name)
-			if err != nil {
-				return errors.New("error dropping table " + t.Name + ": " + err.Error())
+	return Operation{
+		Description:  "drop relation " + r.From + "." + r.name(),
+		Forward:      dropRelationQueries(r),
+		Reverse:      reverse,
+		Destructive:  r.dropsData(),
+		LossyReverse: r.dropsData(),
+	}, true
+}
+
+// Get the operation to create a relation.
+// Relations with a through model are skipped, the through model is migrated as a table of its own.
+func createRelationOperation(r Relation) (Operation, bool) {
+	if r.Through != "" {
+		return Operation{}, false
+	}
+	t := relationType(string(r.Type))
+	reverse := dropRelationQueries(r)
+	if t == relOneToOne {
+		// Dropping a one to one relation keeps the join table, undoing its creation does not.
+		reverse = []string{dropRelationTableQuery(r)}
+	}
+	return Operation{
+		Description:  "create relation " + r.From + "." + r.name(),
+		Forward:      createRelationQueries(r),
+		Reverse:      reverse,
+		LossyReverse: r.dropsData(),
+	}, true
+}
+
+// Find a column of a table in the migration.
+func (m Migration) column(table, name string) (Column, bool) {
+	for _, t := range m.Tables {
+		if t.Name != table {
+			continue
+		}
+		for _, c := range t.Columns {
+			if c.Name == name {
+				return c, true
 			}
-			migrations++
 		}
 	}
+	return Column{}, false
+}
 
-	if len(removed_columns) > 0 {
-		// Remove removed columns
-		for _, c := range removed_columns {
-			m.Database.Logger.Debug("MIGRATION: removing column ", c.Name, " from table ", c.Table)
-			_, err := m.Database.Exec("ALTER TABLE " + c.Table + " DROP COLUMN " + c.Name)
-			if err != nil {
-				return errors.New("error removing column " + c.Table + "." + c.Name + ": " + err.Error())
+// Find a relation in the migration, matching on the tables and the name of the relation.
+func (m Migration) relation(r Relation) (Relation, bool) {
+	for _, t := range m.Tables {
+		for _, o := range t.Relations {
+			if r.From == o.From && r.To == o.To && r.name() == o.name() {
+				return o, true
 			}
-			migrations++
 		}
 	}
+	return Relation{}, false
+}
 
-	if len(removed_relations) > 0 {
-		// Remove removed relations
-		for _, r := range removed_relations {
-			m.Database.Logger.Debug("MIGRATION: removing relation for ", r.From, " and", r.To)
-			switch strings.ToLower(string(r.Type)) {
-			case "fk", "foreignkey":
-				err := m.Database.DropFKTable(r.From, r.To)
-				if err != nil {
-					return errors.New("error dropping relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				}
-			case "1t1", "onetoone":
-				err := m.Database.AlterDropOneToOne(r.From, r.To)
-				if err != nil {
-					return errors.New("error dropping relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				}
-				//case "otm", "onetomany":
-				//	err := m.Database.AlterDropOneToMany(r.From, r.To)
-				//	if err != nil {
-				//		return errors.New("error dropping relation table for " + r.From + " and " + r.To + ": " + err.Error())
-				//	}
+// Apply the operations of a migration to the database.
+// Returns the number of operations executed.
+func (m Migration) execute(ctx context.Context, operations []Operation) (int, error) {
+	var migrations int = 0
+	for _, op := range operations {
+		if op.Destructive {
+			m.Database.Logger.Warning("MIGRATION: ", op.Description, " removes data from the database")
+		} else {
+			m.Database.Logger.Debug("MIGRATION: ", op.Description)
+		}
+		for _, query := range op.Forward {
+			if _, err := m.Database.ExecContext(ctx, m.Database.resolveForeignKey(ctx, query)); err != nil {
+				return migrations, errors.New("failed to " + op.Description + ": " + err.Error())
 			}
-			migrations++
 		}
+		migrations++
 	}
+	m.Database.Logger.Debug(fmt.Sprintf("%s migrations applied", strconv.Itoa(migrations)))
+	return migrations, nil
+}
 
-	m.Database.LatestMigration = &m
+// Get the queries to create a relation when migrating.
+func createRelationQueries(r Relation) []string {
+	switch relationType(string(r.Type)) {
+	case relManyToMany:
+		return []string{createRelationTableQuery(r)}
+	case relOneToOne:
+		// The relation table is needed for the one to one constraint.
+		return []string{createRelationTableQuery(r), oneToOneQuery(r)}
+	case relBelongsTo:
+		return []string{addForeignKeyQuery(r)}
+	case relOneToMany:
+		return []string{addOneToManyQuery(r)}
+	}
+	return nil
+}
 
-	if migrations > 0 {
-		// Write migration to FS
-		m.Database.Logger.Debug(fmt.Sprintf("%s migrations applied", strconv.Itoa(migrations)))
-		return m.Write()
-	} else {
-		return errors.New("no migrations to run")
+// Get the queries to alter the referential actions of a relation when migrating.
+// The foreign key constraints are dropped, and added again with the new actions.
+func alterRelationQueries(r Relation) []string {
+	var constraints []Relation
+	switch relationType(string(r.Type)) {
+	case relManyToMany, relOneToOne:
+		constraints = joinForeignKeys(r)
+	case relBelongsTo:
+		constraints = []Relation{r}
+	case relOneToMany:
+		constraints = []Relation{oneToManyForeignKey(r)}
 	}
+	queries := []string{}
+	for _, c := range constraints {
+		queries = append(queries, dropForeignKeyQuery(c.From, c.Column), addForeignKeyQuery(c))
+	}
+	return queries
+}
+
+// Get the queries to drop a relation when migrating.
+func dropRelationQueries(r Relation) []string {
+	switch relationType(string(r.Type)) {
+	case relManyToMany:
+		return []string{dropRelationTableQuery(r)}
+	case relOneToOne:
+		from, _ := r.joinColumns()
+		return []string{dropForeignKeyQuery(r.joinTable(), from)}
+	case relBelongsTo:
+		return []string{dropForeignKeyQuery(r.From, r.Column)}
+	case relOneToMany:
+		return dropOneToManyQueries(r)
+	}
+	return nil
 }
 
 // Read the latest migration from the file system
 func (m Migration) GetLatestMigration() (Migration, error) {
-	files, err := os.ReadDir(m.Directory)
+	files, err := m.ReadMigrations()
 	if err != nil {
-		err = os.Mkdir(m.Directory, 0755)
-		if err != nil {
-			return Migration{}, errors.New("could not create migrations folder")
-		}
-	}
-	// Find out when latest migration was last run
-	latest_time := time.Time{}
-	latest_index := 0
-	for i, f := range files {
-		if f.IsDir() {
-			continue
-		}
-		if strings.HasPrefix(f.Name(), "Migration_") {
-			t, err := time.Parse("2006-01-02-15-04-05", strings.TrimPrefix(strings.TrimSuffix(f.Name(), ".json"), "Migration_"))
-			if err != nil {
-				return Migration{}, errors.New("failed to parse migration file name: " + f.Name())
-			}
-			if t.After(latest_time) {
-				latest_time = t
-				latest_index = i
-			}
-		}
+		return Migration{}, err
 	}
-	// Read latest migration
-	latest_migration := Migration{}
-	if !latest_time.IsZero() {
-		if len(files) < latest_index {
-			return Migration{}, errors.New("latest migration file not found")
-		}
-		latest_migration_file, err := os.ReadFile(m.Directory + files[latest_index].Name())
-		if err != nil {
-			return Migration{}, errors.New("failed to read latest migration: " + err.Error())
-		}
-		err = json.Unmarshal(latest_migration_file, &latest_migration)
-		if err != nil {
-			return Migration{}, errors.New("failed to parse latest migration: " + err.Error())
-		}
+	if len(files) == 0 {
+		return Migration{}, nil
 	}
-	return latest_migration, nil
+	return files[len(files)-1].Migration, nil
 }
 
 // Write a migration to the file system
 func (m Migration) Write() error {
+	_, err := m.write()
+	return err
+}
+
+// Write a migration to the file system, and return the name of the migration.
+func (m Migration) write() (string, error) {
 	migration_file, err := json.MarshalIndent(m, "", "    ")
 	if err != nil {
-		return errors.New("failed to marshal migration: " + err.Error())
+		return "", errors.New("failed to marshal migration: " + err.Error())
+	}
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return "", err
 	}
-	fname := "./migrations/Migration_" + time.Now().Format("2006-01-02-15-04-05") + ".json"
+	var latest string
+	if len(files) > 0 {
+		latest = files[len(files)-1].Name
+	}
+	name := migrationName(time.Now(), latest)
+	fname := filepath.Join(m.Directory, name+".json")
 	m.Database.Logger.Debug("Writing migration to ", fname)
-	err = os.WriteFile(fname, migration_file, 0644)
+	// An existing migration file is never overwritten.
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
-		return errors.New("failed to write migration file: " + err.Error())
+		return "", errors.New("failed to write migration file: " + err.Error())
 	}
-	return nil
+	defer f.Close()
+	if _, err := f.Write(migration_file); err != nil {
+		return "", errors.New("failed to write migration file: " + err.Error())
+	}
+	return name, nil
+}
+
+// Layout of the time in the name of a migration file, with microseconds so the names sort in the order they were made.
+// Files made before microseconds were added have no fraction, and sort before the files made in the same second.
+const migrationTimeLayout = "2006-01-02-15-04-05.000000"
+
+// Get the name of a new migration file, made at a time.
+// The name always sorts after the latest migration file,
+// also if it was made in the same microsecond, or the clock went back.
+func migrationName(now time.Time, latest string) string {
+	name := "Migration_" + now.Format(migrationTimeLayout)
+	if latest == "" || name > latest {
+		return name
+	}
+	// The fraction of the seconds is parsed, even though the layout has none.
+	t, err := time.Parse("2006-01-02-15-04-05", strings.TrimPrefix(latest, "Migration_"))
+	if err != nil {
+		return name
+	}
+	return "Migration_" + t.Add(time.Microsecond).Format(migrationTimeLayout)
 }
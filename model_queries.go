@@ -1,6 +1,7 @@
 package simpledb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
@@ -15,6 +16,10 @@ func (d *Database) Filter(model Model, filter Filters, include []string) ModelSe
 // Also allows you to specify a limit on the number of results returned.
 func (d *Database) FilterWithLimit(model Model, filters Filters, limit int, include []string) ModelSet {
 	var query string = `SELECT * FROM ` + model.TableName()
+	filters, err := filters.resolve(d, d.meta(model), "")
+	if err != nil {
+		panic(err)
+	}
 	f_query, values := filters.Query(false)
 	if f_query == "" {
 		return nil
@@ -27,13 +32,12 @@ func (d *Database) FilterWithLimit(model Model, filters Filters, limit int, incl
 		panic(err)
 		// return nil
 	}
-	return ScanRows(results, model, include)
+	return scanRows(context.Background(), d, d.meta(model), results, model, include)
 }
 
 // AllQ returns a query that will return all rows in the table.
 func (d *Database) AllQ(model Model, exclude []string) string {
-	cols := Columns(model)
-	cols = Exclude(cols, exclude)
+	cols := d.meta(model).columns(exclude...)
 	query := "SELECT "
 	for i, col := range cols {
 		query += col
@@ -48,34 +52,125 @@ func (d *Database) AllQ(model Model, exclude []string) string {
 }
 
 // Insert a model into the database.
-// Takes a pointer to a model and a sql.Row and scans the ID of result into the model
+// Takes a pointer to a model and a sql.Row and scans the ID of result into the model.
+// If the primary key has a GENERATE tag, the key is generated before inserting.
+// Otherwise, integer primary keys are set to the ID of the inserted row.
 func (d *Database) InsertModel(model Model) error {
-	columns := Columns(model)
-	values := make([]interface{}, len(columns))
-	for i, column := range columns {
-		values[i] = GetValue(model, column)
+	return d.InsertModelContext(context.Background(), model)
+}
+
+// See InsertModel.
+// The context is passed to the model's lifecycle hooks.
+func (d *Database) InsertModelContext(ctx context.Context, model Model) error {
+	var meta = d.meta(model)
+	columns, values, err := d.prepareInsert(ctx, model)
+	if err != nil {
+		return err
+	}
+	var auto = autoIncrementKey(meta, model)
+	res, err := d.ExecContext(ctx, d.InsertQuery(model.TableName(), columns), values...)
+	if err != nil {
+		return err
+	}
+	if auto {
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		pk, _ := primaryKey(meta, model)
+		setValue(meta, model, pk, id)
+	}
+	return afterInsert(ctx, model)
+}
+
+// Insert multiple models of the same type in a single query.
+// Generated primary keys are set on the models,
+// auto incremented primary keys are not.
+func (d *Database) BulkInsert(models []Model) error {
+	return d.BulkInsertContext(context.Background(), models)
+}
+
+// See BulkInsert.
+// The context is passed to the models' lifecycle hooks.
+func (d *Database) BulkInsertContext(ctx context.Context, models []Model) error {
+	if len(models) == 0 {
+		return nil
 	}
-	id, err := d.ExecInsert(model.TableName(), columns, values)
+	var columns []string
+	var values = make([]any, 0)
+	for _, model := range models {
+		if modelKind(model) != modelKind(models[0]) {
+			return errors.New("cannot bulk insert models of different types")
+		}
+		cols, vals, err := d.prepareInsert(ctx, model)
+		if err != nil {
+			return err
+		}
+		columns = cols
+		values = append(values, vals...)
+	}
+	_, err := d.ExecContext(ctx, d.BulkInsertQuery(models[0].TableName(), columns, len(models)), values...)
 	if err != nil {
 		return err
 	}
-	SetValue(model, "id", id)
+	for _, model := range models {
+		if err := afterInsert(ctx, model); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Run the hooks, generate the primary key, set the timestamps and validate a model before inserting it.
+// Returns the columns and values to insert.
+func (d *Database) prepareInsert(ctx context.Context, model Model) ([]string, []any, error) {
+	var meta = d.meta(model)
+	if err := beforeInsert(ctx, model); err != nil {
+		return nil, nil, err
+	}
+	if err := generateKey(meta, model); err != nil {
+		return nil, nil, err
+	}
+	SetTimestamps(model, d.Now(), true)
+	if d.ValidateOnSave {
+		if err := d.Validate(model); err != nil {
+			return nil, nil, err
+		}
+	}
+	return modelValues(d, meta, model)
+}
+
 // Update a model in the database.
 func (d *Database) UpdateModel(model Model) (Model, error) {
-	columns := Columns(model)
-	values := make([]interface{}, len(columns))
-	for i, column := range columns {
-		values[i] = GetValue(model, column)
+	return d.UpdateModelContext(context.Background(), model)
+}
+
+// See UpdateModel.
+// The context is passed to the model's lifecycle hooks.
+func (d *Database) UpdateModelContext(ctx context.Context, model Model) (Model, error) {
+	if err := beforeUpdate(ctx, model); err != nil {
+		return nil, err
+	}
+	SetTimestamps(model, d.Now(), false)
+	if d.ValidateOnSave {
+		if err := d.Validate(model); err != nil {
+			return nil, err
+		}
 	}
-	id, err := d.ExecUpdate(model.TableName(), columns, values, "id = ?", GetValue(model, "id"))
+	var meta = d.meta(model)
+	columns, values, err := modelValues(d, meta, model)
 	if err != nil {
 		return nil, err
 	}
-	// Set the ID of the model to the ID of the updated model
-	SetValue(model, "id", id)
+	pk, pk_value := primaryKey(meta, model)
+	values = append(values, pk_value)
+	_, err = d.ExecContext(ctx, d.UpdateQuery(model.TableName(), columns, pk+" = ?"), values...)
+	if err != nil {
+		return nil, err
+	}
+	if err := afterUpdate(ctx, model); err != nil {
+		return nil, err
+	}
 	// Return the updated model
 	return model, nil
 }
@@ -87,17 +182,43 @@ func (d *Database) AllModel(model Model, include []string) ModelSet {
 	if err != nil {
 		return nil
 	}
-	ms := ScanRows(rows, model, include)
+	ms := scanRows(context.Background(), d, d.meta(model), rows, model, include)
 	rows.Close()
 	return ms
 }
 
 // Scan rows into models, put those into a ModelSet
 func ScanRows(rows *sql.Rows, model Model, include []string) ModelSet {
+	return ScanRowsContext(context.Background(), rows, model, include)
+}
+
+// See ScanRows.
+// The context is passed to the AfterFind hook of the models.
+// ENCRYPTED fields need the keys of a database, use Database.ScanRowsContext for models with ENCRYPTED fields.
+func ScanRowsContext(ctx context.Context, rows *sql.Rows, model Model, include []string) ModelSet {
+	return scanRows(ctx, nil, GetMeta(model), rows, model, include)
+}
+
+// Scan rows into models, with the naming strategy and the keys of the database. See ScanRows.
+func (d *Database) ScanRows(rows *sql.Rows, model Model, include []string) ModelSet {
+	return d.ScanRowsContext(context.Background(), rows, model, include)
+}
+
+// See Database.ScanRows.
+// The context is passed to the AfterFind hook of the models.
+func (d *Database) ScanRowsContext(ctx context.Context, rows *sql.Rows, model Model, include []string) ModelSet {
+	return scanRows(ctx, d, d.meta(model), rows, model, include)
+}
+
+// See ScanRowsContext.
+func scanRows(ctx context.Context, db *Database, meta *ModelMeta, rows *sql.Rows, model Model, include []string) ModelSet {
 	var models []Model
 	for rows.Next() {
 		model := NewModel(model)
-		if err := Scan(model, rows, include); err != nil {
+		if err := scan(db, meta, model, rows, include); err != nil {
+			panic(err)
+		}
+		if err := afterFind(ctx, model); err != nil {
 			panic(err)
 		}
 		models = append(models, model)
@@ -107,21 +228,52 @@ func ScanRows(rows *sql.Rows, model Model, include []string) ModelSet {
 
 // Scan a row into a model
 func ScanRow(row *sql.Row, model Model, include []string) (Model, error) {
+	return ScanRowContext(context.Background(), row, model, include)
+}
+
+// See ScanRow.
+// The context is passed to the AfterFind hook of the model.
+// ENCRYPTED fields need the keys of a database, use Database.ScanRowContext for models with ENCRYPTED fields.
+func ScanRowContext(ctx context.Context, row *sql.Row, model Model, include []string) (Model, error) {
+	return scanRowContext(ctx, nil, row, model, include)
+}
+
+// Scan a row into a model, with the naming strategy and the keys of the database. See ScanRow.
+func (d *Database) ScanRow(row *sql.Row, model Model, include []string) (Model, error) {
+	return d.ScanRowContext(context.Background(), row, model, include)
+}
+
+// See Database.ScanRow.
+// The context is passed to the AfterFind hook of the model.
+func (d *Database) ScanRowContext(ctx context.Context, row *sql.Row, model Model, include []string) (Model, error) {
+	return scanRowContext(ctx, d, row, model, include)
+}
+
+// See ScanRowContext.
+func scanRowContext(ctx context.Context, db *Database, row *sql.Row, model Model, include []string) (Model, error) {
 	model = NewModel(model)
-	fields, err := modelFields(model, include)
-	if err != nil {
-		return nil, errors.New("modelFields: " + err.Error())
-	}
-	err = row.Scan(fields...)
+	err := scanRow(db, db.meta(model), model, row, include)
 	if err != nil {
-		err = errors.New("no results found: " + err.Error())
+		return model, errors.New("no results found: " + err.Error())
 	}
-
-	return model, err
+	return model, afterFind(ctx, model)
 }
 
 // Delete a model from the database
 func (d *Database) DeleteModel(model Model) error {
-	_, err := d.Exec("DELETE FROM "+model.TableName()+" WHERE id = ?", GetValue(model, "id"))
-	return err
+	return d.DeleteModelContext(context.Background(), model)
+}
+
+// See DeleteModel.
+// The context is passed to the model's lifecycle hooks.
+func (d *Database) DeleteModelContext(ctx context.Context, model Model) error {
+	if err := beforeDelete(ctx, model); err != nil {
+		return err
+	}
+	pk, pk_value := primaryKey(d.meta(model), model)
+	_, err := d.ExecContext(ctx, "DELETE FROM "+model.TableName()+" WHERE "+pk+" = ?", pk_value)
+	if err != nil {
+		return err
+	}
+	return afterDelete(ctx, model)
 }
@@ -0,0 +1,60 @@
+package simpledb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy decides the column name for a struct field,
+// when the column name is not set with the COLUMN tag.
+type NamingStrategy interface {
+	ColumnName(field string) string
+}
+
+// NamingFunc is a function which implements NamingStrategy.
+type NamingFunc func(field string) string
+
+// Get the column name for a struct field.
+func (f NamingFunc) ColumnName(field string) string {
+	return f(field)
+}
+
+var (
+	// Lowercase the struct field name. (CreatedAt -> createdat)
+	LowerCase NamingStrategy = NamingFunc(strings.ToLower)
+	// Convert the struct field name to snake case. (CreatedAt -> created_at)
+	SnakeCase NamingStrategy = NamingFunc(ToSnakeCase)
+)
+
+// The naming strategy used for new databases, and by the package level helpers such as Columns and GetValue.
+// Defaults to SnakeCase.
+// Tables created before the naming strategy was introduced use LowerCase column names,
+// set the NamingStrategy of their database to LowerCase before registering any models.
+var DefaultNamingStrategy = SnakeCase
+
+// Convert a string to snake case.
+// Example:
+//
+//	ToSnakeCase("CreatedAt") // created_at
+//	ToSnakeCase("UserID")    // user_id
+//	ToSnakeCase("HTTPPort")  // http_port
+func ToSnakeCase(s string) string {
+	var runes = []rune(s)
+	var b strings.Builder
+	b.Grow(len(s) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				var prevLower = unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				var nextLower = i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					b.WriteRune('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
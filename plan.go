@@ -0,0 +1,120 @@
+package simpledb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+)
+
+// MigrationPlan is the list of operations a migration would run, in order.
+type MigrationPlan []Operation
+
+// Get the SQL statements of the plan, in the order they would be executed.
+func (p MigrationPlan) Statements() []string {
+	statements := []string{}
+	for _, op := range p {
+		statements = append(statements, op.Forward...)
+	}
+	return statements
+}
+
+// Generate a SQL script for the plan.
+// Every operation is preceded by a comment describing it, destructive operations are marked as such.
+func (p MigrationPlan) SQL() string {
+	var b strings.Builder
+	for i, op := range p {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("-- " + op.Description)
+		if op.Destructive {
+			b.WriteString(" (destructive)")
+		}
+		b.WriteString("\n")
+		for _, query := range op.Forward {
+			b.WriteString(query + ";\n")
+		}
+	}
+	return b.String()
+}
+
+// Write the SQL script of the plan to a file, usually with a .sql extension.
+func (p MigrationPlan) WriteSQL(path string) error {
+	if err := os.WriteFile(path, []byte(p.SQL()), 0644); err != nil {
+		return errors.New("failed to write migration plan: " + err.Error())
+	}
+	return nil
+}
+
+// Get the operations Run would execute, in order.
+// These are the operations of the migration files which were not applied yet,
+// followed by the changes of the models which are not in a migration file yet.
+// The applied migrations are read from the database, but nothing is changed.
+// The plan can be reviewed or exported before running the migration.
+// Example:
+//
+//	plan, err := migration.Plan()
+//	for _, op := range plan {
+//	    fmt.Println(op.Description)
+//	}
+//	err = plan.WriteSQL("migration.sql")
+func (m Migration) Plan() (MigrationPlan, error) {
+	return m.PlanContext(context.Background())
+}
+
+// Get the operations Run would execute, in order.
+// See Migration.Plan.
+func (m Migration) PlanContext(ctx context.Context) (MigrationPlan, error) {
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedNames(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	plan := MigrationPlan{}
+	var previous Migration
+	for _, f := range files {
+		if !applied[f.Name] {
+			plan = append(plan, f.Migration.operations(previous)...)
+		}
+		previous = f.Migration
+	}
+	if m.Changed(previous) {
+		plan = append(plan, m.Diff(previous)...)
+	}
+	return plan, nil
+}
+
+// Get the operations of the model changes which are not in a migration file yet.
+// Only the models are compared to the latest migration file, migration files which were not applied are not included.
+// The database is not used, this previews the migration file makemigrations would write.
+func (m Migration) PlanChanges() (MigrationPlan, error) {
+	latest_migration, err := m.GetLatestMigration()
+	if err != nil {
+		return nil, err
+	}
+	return MigrationPlan(m.Diff(latest_migration)), nil
+}
+
+// Get the operations of a migration file, by the name of the file.
+// Used to review the SQL of a migration which was already made.
+func (m Migration) PlanFile(name string) (MigrationPlan, error) {
+	files, err := m.ReadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		if f.Name != name {
+			continue
+		}
+		var previous Migration
+		if i > 0 {
+			previous = files[i-1].Migration
+		}
+		return MigrationPlan(f.Migration.operations(previous)), nil
+	}
+	return nil, errors.New("unknown migration " + name)
+}
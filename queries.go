@@ -2,6 +2,7 @@ package simpledb
 
 import (
 	"database/sql"
+	"strings"
 )
 
 // Get the tables from the database
@@ -147,6 +148,19 @@ func (d *Database) InsertQuery(table string, columns []string) string {
 	return query
 }
 
+// Insert multiple rows into a table.
+func (d *Database) BulkInsertQuery(table string, columns []string, rows int) string {
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES "
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	for i := 0; i < rows; i++ {
+		query += row
+		if i < rows-1 {
+			query += ", "
+		}
+	}
+	return query
+}
+
 // Update a row in a table.
 func (d *Database) UpdateQuery(table string, columns []string, where string) string {
 	query := "UPDATE " + table + " SET "
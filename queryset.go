@@ -1,6 +1,7 @@
 package simpledb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -20,6 +21,13 @@ type QuerySet struct {
 	OFFSET     int
 	LIMIT      int
 	PAGESIZE   int
+	related    []*FieldMeta
+	joins      []string
+	ordered    []string
+	distinct   bool
+	prefetch   []*FieldMeta
+	ctx        context.Context
+	err        error
 }
 
 // Initialize the QuerySet
@@ -37,6 +45,21 @@ func NewQuerySet(db *Database, model ...Model) *QuerySet {
 	return qs
 }
 
+// Set the context the queries are executed with.
+// The context is also passed to the AfterFind hooks of the models.
+func (q *QuerySet) WithContext(ctx context.Context) *QuerySet {
+	q.ctx = ctx
+	return q
+}
+
+// Get the context of the QuerySet, defaults to context.Background().
+func (q *QuerySet) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+
 // Add SQL statement to the QuerySet
 func (q *QuerySet) Add(statement string) *QuerySet {
 	q.Statements = append(q.Statements, statement)
@@ -49,10 +72,47 @@ func (q *QuerySet) AddFiters(filters Filters) *QuerySet {
 	return q
 }
 
+// Get the first error which occurred while building the query,
+// such as a filter on an encrypted field which cannot be resolved.
+// Exec and ExecRow return this error instead of executing the query.
+func (q *QuerySet) Err() error {
+	return q.err
+}
+
+// Keep the first error which occurred while building the query.
+func (q *QuerySet) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
 // Generate the SQL query and values to be passed to the database
+// If the query cannot be built, an empty query is returned, and the error is available through Err.
 func (q *QuerySet) Query() (string, []interface{}) {
-	f_query, values := q.Filters.Query(true)
-	q.Q = strings.Join(q.Statements, " ") + f_query
+	filters := q.Filters
+	statements := q.Statements
+	if q.Model != nil {
+		meta := q.db.meta(q.Model)
+		spanned := q.spanFilters(filters)
+		// The columns of the model are qualified with its table when other tables are joined.
+		var table string
+		if q.joined() {
+			table = meta.Table
+		}
+		resolved, err := spanned.resolve(q.db, meta, table)
+		if err != nil {
+			q.fail(err)
+		}
+		filters = resolved
+	}
+	if q.err != nil {
+		return "", nil
+	}
+	if q.joined() {
+		statements = q.joinStatements(q.db.meta(q.Model))
+	}
+	f_query, values := filters.Query(true)
+	q.Q = strings.Join(statements, " ") + f_query
 	if q.PAGESIZE > 0 {
 		q.Q += fmt.Sprintf(" LIMIT %d OFFSET %d", q.PAGESIZE, q.OFFSET)
 	} else if q.LIMIT > 0 {
@@ -86,17 +146,20 @@ func (q *QuerySet) Count() *QuerySet {
 // Select specific columns from the table
 func (q *QuerySet) Select(columns ...string) *QuerySet {
 	for _, column := range columns {
+		if q.Model != nil {
+			column = q.db.meta(q.Model).ColumnName(column)
+		}
 		if !typeutils.Contains(q.exclude, column) {
 			q.exclude = append(q.exclude, column)
 		}
 	}
-	q.Add(fmt.Sprintf(`SELECT %s`, strings.Join(columns, ", ")))
+	q.Add(fmt.Sprintf(`SELECT %s`, strings.Join(q.columns(columns), ", ")))
 	return q
 }
 
 // Group by a column
 func (q *QuerySet) GroupBy(columns ...string) *QuerySet {
-	q.Add(fmt.Sprintf(`GROUP BY %s`, strings.Join(columns, ", ")))
+	q.Add(fmt.Sprintf(`GROUP BY %s`, strings.Join(q.columns(columns), ", ")))
 	return q
 }
 
@@ -108,6 +171,11 @@ func (q *QuerySet) Raw(sql string) (string, []any) {
 }
 
 // Where adds a where clause to end of the query
+// Columns of related models are looked up by following the relations,
+// separated by double underscores. The related tables are joined automatically.
+// Example:
+//
+//	qs.All().Where("author__name", "=", "X")
 func (q *QuerySet) Where(column string, op string, value any) *QuerySet {
 	q.Filters = q.Filters.Add(column, op, value)
 	return q
@@ -127,7 +195,14 @@ func (q *QuerySet) From(table ...string) *QuerySet {
 }
 
 // OrderBy sets the order of the query
+// Columns of related models can be used, see Where.
 func (q *QuerySet) OrderBy(column string, order string) *QuerySet {
+	if spanned, ok := q.span(column); ok {
+		column = spanned
+	} else {
+		column = q.columns([]string{column})[0]
+	}
+	q.ordered = append(q.ordered, column)
 	q.Add(fmt.Sprintf(`ORDER BY %s %s`, column, order))
 	return q
 }
@@ -163,13 +238,19 @@ func (q *QuerySet) Get(values ...int) *QuerySet {
 // Execute the query and return the results
 func (q *QuerySet) Exec() (*sql.Rows, error) {
 	query, vals := q.Query()
-	return q.db.Query(query, vals...)
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.db.QueryContext(q.context(), query, vals...)
 }
 
 // Execute the query and return the results
 func (q *QuerySet) ExecRow() (*sql.Row, error) {
 	query, vals := q.Query()
-	return q.db.QueryRow(query, vals...), nil
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.db.QueryRowContext(q.context(), query, vals...), nil
 }
 
 // Execute the query and return the results
@@ -191,8 +272,14 @@ func (q *QuerySet) MultiModel(model ...Model) ModelSet {
 		panic(err)
 	}
 	defer rows.Close()
-	ms := ScanRows(rows, q.Model, q.exclude)
+	ms, err := q.scanModels(q.context(), rows)
+	if err != nil {
+		panic(err)
+	}
 	rows.Close()
+	if err := q.prefetchRelated(ms); err != nil {
+		panic(err)
+	}
 	return ms
 }
 
@@ -204,12 +291,27 @@ func (q *QuerySet) SingleModel(model ...Model) (Model, error) {
 	if q.Model == nil {
 		panic("no model provided")
 	}
-	row, err := q.ExecOne()
+	q.Limit(1)
+	rows, err := q.Exec()
 	if err != nil {
 		return nil, errors.New("no results found: " + err.Error())
 	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, errors.New("no results found: " + err.Error())
+		}
+		return nil, errors.New("no results found: " + sql.ErrNoRows.Error())
+	}
 	newmodel := NewModel(q.Model)
-	return ScanRow(row, newmodel, q.exclude)
+	if err := q.scan(q.context(), newmodel, rows); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := afterFind(q.context(), newmodel); err != nil {
+		return nil, err
+	}
+	return newmodel, q.prefetchRelated(ModelSet{newmodel})
 }
 
 // Paginate the results
@@ -219,6 +321,84 @@ func (q *QuerySet) Page(page int) ModelSet {
 	return q.MultiModel()
 }
 
+// Resolve struct field names to the column names of the model.
+func (q *QuerySet) columns(columns []string) []string {
+	if q.Model == nil {
+		return columns
+	}
+	meta := q.db.meta(q.Model)
+	resolved := make([]string, len(columns))
+	for i, column := range columns {
+		if spanned, ok := q.span(column); ok {
+			resolved[i] = spanned
+			continue
+		}
+		resolved[i] = q.qualify(meta, meta.ColumnName(column))
+	}
+	return resolved
+}
+
+// Check if other tables are joined to the table of the model.
+func (q *QuerySet) joined() bool {
+	return q.Model != nil && (len(q.related) > 0 || len(q.joins) > 0)
+}
+
+// Rewrite the statements of the query when other tables are joined.
+// SELECT * selects the columns of the model and the selected related models,
+// the other tables are joined after the FROM statement.
+// Rows are selected DISTINCT if a to-many relation is joined.
+func (q *QuerySet) joinStatements(meta *ModelMeta) []string {
+	var distinct string
+	if q.distinct {
+		distinct = `DISTINCT `
+	}
+	statements := make([]string, len(q.Statements))
+	for i, statement := range q.Statements {
+		switch {
+		case statement == `SELECT *`:
+			statement = `SELECT ` + distinct + strings.Join(q.relatedColumns(meta), ", ")
+		case statement == `SELECT COUNT(*)` && q.distinct:
+			statement = `SELECT COUNT(DISTINCT ` + meta.Table + `.` + meta.PrimaryColumn() + `)`
+		case strings.HasPrefix(statement, `SELECT `) && !strings.HasPrefix(statement, `SELECT DISTINCT `):
+			statement = strings.TrimPrefix(statement, `SELECT `)
+			if q.distinct {
+				// Columns in the ORDER BY clause must be selected with DISTINCT.
+				if ordered := q.orderedColumns(strings.Split(statement, `, `)); len(ordered) > 0 {
+					statement += `, ` + strings.Join(ordered, `, `)
+				}
+			}
+			statement = `SELECT ` + distinct + statement
+		case statement == `FROM `+meta.Table:
+			joins := []string{}
+			for _, join := range append(q.relatedJoins(meta), q.joins...) {
+				if !typeutils.Contains(joins, join) {
+					joins = append(joins, join)
+				}
+			}
+			statement += ` ` + strings.Join(joins, ` `)
+		}
+		statements[i] = statement
+	}
+	return statements
+}
+
+// Qualify the columns of the model with the table name.
+// Needed when other tables are joined, to avoid ambiguous column names.
+func (q *QuerySet) qualify(meta *ModelMeta, column string) string {
+	if f, ok := meta.Field(column); ok && f.Column == column {
+		return meta.Table + `.` + column
+	}
+	return column
+}
+
+// Qualify a column with a table or alias, the column is returned as is if the table is empty.
+func qualified(table, column string) string {
+	if table == "" {
+		return column
+	}
+	return table + `.` + column
+}
+
 // Setup a basic query, added so you don't have to type .All().From() every time.
 func (q *QuerySet) setup() {
 	if len(q.Statements) < 2 && q.Model != nil {
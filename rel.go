@@ -1,105 +1,645 @@
 package simpledb
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Check if a relation type is a belongs-to relation.
+// Belongs-to relations are stored in a foreign key column on the owning table.
+// Example:
+//
+//	type Order struct {
+//	    Rel_customer *Customer `simpledb:"RELTYPE:BELONGSTO"` // Stored in order.customer_id
+//	}
+func isBelongsTo(reltype string) bool {
+	return relationType(reltype) == relBelongsTo
+}
+
+// Normalized relation types.
+const (
+	relBelongsTo  = "belongsto"
+	relOneToOne   = "onetoone"
+	relManyToMany = "manytomany"
+	relOneToMany  = "onetomany"
+)
+
+// Normalize the value of a RELTYPE tag.
+// Returns an empty string for unknown relation types.
+func relationType(reltype string) string {
+	switch strings.ToLower(reltype) {
+	case "belongsto", "belongs_to", "manytoone", "mto":
+		return relBelongsTo
+	case "1t1", "onetoone":
+		return relOneToOne
+	case "fk", "foreignkey", "m2m", "manytomany":
+		return relManyToMany
+	case "otm", "onetomany":
+		return relOneToMany
+	}
+	return ""
+}
+
+// Get a related field by its relation name, struct field name or foreign key column.
+// Example: "customer", "Rel_customer" or "customer_id"
+func (m *ModelMeta) Relation(name string) (*FieldMeta, bool) {
+	for _, f := range m.Relations {
+		if strings.EqualFold(relationName(f), name) || strings.EqualFold(f.Name, name) || (f.ForeignKey && strings.EqualFold(f.Column, name)) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Get the foreign key column on the child table of a one to many relation.
+// Defaults to the table name of the parent model, suffixed with _id.
+// Example:
+//
+//	type Customer struct {
+//	    ID          int64    `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+//	    Rel_orders  []*Order `simpledb:"RELTYPE:OTM"` // Stored in orders.customer_id
+//	}
+func reverseColumn(meta *ModelMeta, f *FieldMeta) string {
+	if column := f.Tags.Column(); column != "" {
+		return column
+	}
+	return meta.Table + "_id"
+}
+
+// Get the foreign key constraint on the child table of a one to many relation.
+func oneToManyForeignKey(r Relation) Relation {
+	return Relation{From: r.To, Column: r.Column, To: r.From, References: r.References, OnDelete: r.OnDelete, OnUpdate: r.OnUpdate}
+}
+
+// Add the foreign key column of a one to many relation to the child table.
+// The column is nullable, rows which already exist in the child table have no parent.
+// If the child model declares the column, only the constraint is added.
+func (db *Database) AlterOneToMany(r Relation) error {
+	_, err := db.Exec(addOneToManyQuery(r))
+	return err
+}
+
+// Get the query to add the foreign key column of a one to many relation to the child table.
+// The column has the type of the primary key of the parent, see Relation.Definition.
+func addOneToManyQuery(r Relation) string {
+	if r.Declared {
+		return addForeignKeyQuery(oneToManyForeignKey(r))
+	}
+	definition := r.Definition
+	if definition == "" {
+		definition = r.Column + ` BIGINT NULL`
+	}
+	return `ALTER TABLE ` + r.To + ` ADD COLUMN ` + definition + `, ADD ` + foreignKeyDefinition(oneToManyForeignKey(r))
+}
+
+// Drop the foreign key column of a one to many relation from the child table.
+func (db *Database) AlterDropOneToMany(r Relation) error {
+	for _, query := range dropOneToManyQueries(r) {
+		if _, err := db.Exec(db.resolveForeignKey(context.Background(), query)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the queries to drop the foreign key column of a one to many relation from the child table.
+// The constraint is dropped before the column, columns declared by the child model are kept.
+func dropOneToManyQueries(r Relation) []string {
+	if r.Declared {
+		return []string{dropForeignKeyQuery(r.To, r.Column)}
+	}
+	return []string{
+		dropForeignKeyQuery(r.To, r.Column),
+		`ALTER TABLE ` + r.To + ` DROP COLUMN ` + r.Column,
+	}
+}
+
+// Get the related models of a one to many relation as a QuerySet.
+// The reverse side of a relation with a RELATED_NAME tag can be queried as well,
+// the model declaring the relation must be registered.
+// The QuerySet can be filtered, ordered and paginated further.
+// Example:
+//
+//	qs, err := db.Related(ctx, customer, "orders")
+//	orders := qs.Where("total", ">", 10).OrderBy("id", "DESC").MultiModel()
+func (db *Database) Related(ctx context.Context, parent Model, name string) (*QuerySet, error) {
+	meta := db.meta(parent)
+	f, ok := meta.Relation(name)
+	if !ok {
+		if owner, f, ok := db.reverseRelation(meta, name); ok {
+			return db.relatedReverse(ctx, owner, f, parent)
+		}
+		return nil, errors.New("no relation " + name + " on model " + meta.Table)
+	}
+	if relationType(f.Tags.RelType()) != relOneToMany {
+		return nil, errors.New("relation " + name + " is not a one to many relation")
+	}
+	child, err := f.RelatedMeta()
+	if err != nil {
+		return nil, err
+	}
+	_, pk := primaryKey(db.meta(parent), parent)
+	qs := NewQuerySet(db, reflect.New(child.Type).Interface().(Model)).WithContext(ctx)
+	return qs.All().Where(reverseColumn(meta, f), EQ, pk), nil
+}
+
+// Find the relation of a registered model which points to the model with a RELATED_NAME.
+// Returns the metadata of the registered model, and the related field.
+func (db *Database) reverseRelation(meta *ModelMeta, name string) (*ModelMeta, *FieldMeta, bool) {
+	for _, model := range db.models {
+		owner := db.meta(model)
+		for _, f := range owner.Relations {
+			if strings.EqualFold(f.Tags.RelatedName(), name) && relationTarget(f) == meta.Table {
+				return owner, f, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// Get the models which point to the target model through a relation, as a QuerySet.
+// The table holding the relation is joined to the table of the owning model.
+func (db *Database) relatedReverse(ctx context.Context, owner *ModelMeta, f *FieldMeta, target Model) (*QuerySet, error) {
+	_, pk := primaryKey(db.meta(target), target)
+	qs := NewQuerySet(db, reflect.New(owner.Type).Interface().(Model)).WithContext(ctx)
+	switch relationType(f.Tags.RelType()) {
+	case relBelongsTo:
+		return qs.All().Where(f.Column, EQ, pk), nil
+	case relOneToMany:
+		// The owner is the parent, the target is the child holding the foreign key column.
+		related, _ := f.RelatedMeta()
+		qs.joins = append(qs.joins, fmt.Sprintf(`JOIN %s AS link ON link.%s = %s.%s`,
+			related.Table, reverseColumn(owner, f), owner.Table, owner.PrimaryColumn()))
+		return qs.All().Where(`link.`+related.PrimaryColumn(), EQ, pk), nil
+	}
+	table, from_column, to_column := joinTable(owner, f)
+	qs.joins = append(qs.joins, fmt.Sprintf(`JOIN %s AS link ON link.%s = %s.%s`,
+		table, from_column, owner.Table, owner.PrimaryColumn()))
+	return qs.All().Where(`link.`+to_column, EQ, pk), nil
+}
+
+// Get the join table of a one to one or many to many relation,
+// together with the columns pointing to the owning and the related model.
+func joinTable(meta *ModelMeta, f *FieldMeta) (table, from_column, to_column string) {
+	r := Relation{From: meta.Table, To: relationTarget(f), Name: relationName(f)}
+	from_column, to_column = r.joinColumns()
+	if through := f.Tags.Through(); through != "" {
+		return through, from_column, to_column
+	}
+	return r.joinTable(), from_column, to_column
+}
+
+// Get the name of a relation, the name of the related field without the Rel_ prefix.
+// The name is used to refer to the relation in queries, and to name the join table.
+func relationName(f *FieldMeta) string {
+	return strings.TrimPrefix(f.Name, "Rel_")
+}
+
+// Get the name of the table a related field points to.
+// The table is set with the TO tag, or inferred from the TableName of the related type.
+// Falls back to the name of the relation if the related type is not a model.
+func relationTarget(f *FieldMeta) string {
+	if to := f.Tags.To(); to != "" {
+		return to
+	}
+	if related, err := f.RelatedMeta(); err == nil && related.Table != "" {
+		return related.Table
+	}
+	return relationName(f)
+}
+
+// Find the related field on a model which points to a table, and matches the kind of relation.
+// If a name is given, the relation with that name is returned.
+// Without a name, the model must have exactly one matching relation to the table.
+func relationTo(meta *ModelMeta, table string, match func(f *FieldMeta) bool, name ...string) (*FieldMeta, error) {
+	if len(name) > 0 {
+		f, ok := meta.Relation(name[0])
+		if !ok || relationTarget(f) != table || !match(f) {
+			return nil, errors.New("no matching relation " + name[0] + " from " + meta.Table + " to " + table)
+		}
+		return f, nil
+	}
+	var found *FieldMeta
+	for _, f := range meta.Relations {
+		if relationTarget(f) != table || !match(f) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New("multiple relations from " + meta.Table + " to " + table + ", the name of the relation must be given")
+		}
+		found = f
+	}
+	if found == nil {
+		return nil, errors.New("no matching relation from " + meta.Table + " to " + table)
+	}
+	return found, nil
+}
+
+// Check if a relation is stored in a join table without a through model.
+func isJoinRelation(f *FieldMeta) bool {
+	t := relationType(f.Tags.RelType())
+	return (t == relManyToMany || t == relOneToOne) && f.Tags.Through() == ""
+}
+
+// Check if a relation is stored in a through model.
+func isThroughRelation(f *FieldMeta) bool {
+	return f.Tags.Through() != ""
+}
+
+// Get the join table between two models, for the functions which take the related model instead of the name of the relation.
+// The relation declared on the from model is used if it has exactly one to the table of the to model,
+// otherwise the join table is named after both tables. (from_to)
+func (db *Database) joinLink(from, to Model) link {
+	meta := db.meta(from)
+	_, from_pk := primaryKey(meta, from)
+	if f, err := relationTo(meta, to.TableName(), isJoinRelation); err == nil {
+		table, from_column, to_column := joinTable(meta, f)
+		return link{table: table, from_column: from_column, to_column: to_column, from_pk: from_pk}
+	}
+	r := Relation{From: from.TableName(), To: to.TableName()}
+	from_column, to_column := r.joinColumns()
+	return link{table: r.joinTable(), from_column: from_column, to_column: to_column, from_pk: from_pk}
+}
+
+// Name of the foreign key constraint for a column.
+func foreignKeyName(table, column string) string {
+	return "fk_" + table + "_" + column
+}
+
+// Get the definition of a foreign key constraint, including the referential actions.
+// The constraint is on the Column of the From table, and references the To table.
+func foreignKeyDefinition(r Relation) string {
+	return `CONSTRAINT ` + foreignKeyName(r.From, r.Column) + ` FOREIGN KEY (` + r.Column + `) REFERENCES ` + r.To + `(` + r.References + `)` + r.actions()
+}
+
+// Get the foreign key constraints of the join table of a relation.
+func joinForeignKeys(r Relation) []Relation {
+	table := r.joinTable()
+	from, to := r.joinColumns()
+	from_references, to_references := r.joinReferences()
+	return []Relation{
+		{From: table, Column: from, To: r.From, References: from_references, OnDelete: r.OnDelete, OnUpdate: r.OnUpdate},
+		{From: table, Column: to, To: r.To, References: to_references, OnDelete: r.OnDelete, OnUpdate: r.OnUpdate},
+	}
+}
+
+// Add a foreign key constraint to a table.
+// The constraint is added on the Column of the From table, and references the To table.
+func (db *Database) AddForeignKey(r Relation) error {
+	_, err := db.Exec(addForeignKeyQuery(r))
+	return err
+}
+
+// Get the query to add a foreign key constraint to a table.
+func addForeignKeyQuery(r Relation) string {
+	return `ALTER TABLE ` + r.From + ` ADD ` + foreignKeyDefinition(r)
+}
+
+// Drop a foreign key constraint from a table.
+// The name of the constraint is looked up in the database, see foreignKeyConstraint.
+func (db *Database) DropForeignKey(table, column string) error {
+	_, err := db.Exec(`ALTER TABLE ` + table + ` DROP FOREIGN KEY ` + db.foreignKeyConstraint(context.Background(), table, column))
+	return err
+}
+
+// Get the query to drop a foreign key constraint from a table.
+// The constraint name is replaced with the name in the database when executed, see resolveForeignKey.
+func dropForeignKeyQuery(table, column string) string {
+	return `ALTER TABLE ` + table + ` DROP FOREIGN KEY ` + foreignKeyName(table, column)
+}
+
+// Matches the queries of dropForeignKeyQuery.
+var dropForeignKeyRegex = regexp.MustCompile(`^ALTER TABLE (\w+) DROP FOREIGN KEY fk_(\w+)$`)
+
+// Get the name of the foreign key constraint on a column.
+// Constraints which were not created by the migrations, such as the join tables of older versions,
+// have the names generated by MySQL (<table>_ibfk_N). The name is looked up in the information schema,
+// and defaults to foreignKeyName if the constraint is not found.
+func (db *Database) foreignKeyConstraint(ctx context.Context, table, column string) string {
+	var name string
+	var row = db.QueryRowContext(ctx, `SELECT CONSTRAINT_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY CONSTRAINT_NAME = ? DESC, CONSTRAINT_NAME LIMIT 1`, table, column, foreignKeyName(table, column))
+	if err := row.Scan(&name); err != nil {
+		return foreignKeyName(table, column)
+	}
+	return name
+}
+
+// Replace the constraint name in a query of dropForeignKeyQuery with the name of the constraint in the database.
+// Other queries are returned unchanged.
+func (db *Database) resolveForeignKey(ctx context.Context, query string) string {
+	match := dropForeignKeyRegex.FindStringSubmatch(query)
+	if match == nil || !strings.HasPrefix(match[2], match[1]+"_") {
+		return query
+	}
+	column := strings.TrimPrefix(match[2], match[1]+"_")
+	return `ALTER TABLE ` + match[1] + ` DROP FOREIGN KEY ` + db.foreignKeyConstraint(ctx, match[1], column)
+}
+
+// Get the primary key of the related model of a belongs-to relation.
+// Returns nil if the related model is not set.
+func foreignKeyValue(f *FieldMeta, model reflect.Value) any {
+	rel := f.value(model)
+	if rel.IsNil() {
+		return nil
+	}
+	related, err := f.RelatedMeta()
+	if err != nil {
+		return nil
+	}
+	_, pk := primaryKey(related, rel.Interface().(Model))
+	return pk
+}
+
+// Set the related model of a belongs-to relation to a model with only the primary key set.
+// Setting a nil key clears the relation.
+func setForeignKey(f *FieldMeta, model reflect.Value, key any) {
+	rel := f.value(model)
+	if key == nil {
+		rel.Set(reflect.Zero(f.Type))
+		return
+	}
+	related := reflect.New(f.Type.Elem())
+	meta, err := f.RelatedMeta()
+	if err != nil {
+		return
+	}
+	pk, _ := primaryKey(meta, related.Interface().(Model))
+	setValue(meta, related.Interface().(Model), pk, key)
+	rel.Set(related)
+}
+
 func (db *Database) CreateFKTable(from, to string) error {
-	query := `CREATE TABLE IF NOT EXISTS ` + from + `_` + to + ` (
+	return db.CreateRelationTable(Relation{From: from, To: to})
+}
+
+// Create the join table of a relation.
+// The referential actions of the relation are set on both foreign keys.
+func (db *Database) CreateRelationTable(r Relation) error {
+	_, err := db.Exec(createRelationTableQuery(r))
+	return err
+}
+
+// Get the query to create the join table of a relation.
+// The columns have the types of the primary keys they reference.
+func createRelationTableQuery(r Relation) string {
+	from, to := r.joinColumns()
+	from_type, to_type := r.joinTypes()
+	query := `CREATE TABLE IF NOT EXISTS ` + r.joinTable() + ` (
 		id BIGINT PRIMARY KEY AUTO_INCREMENT,
-		` + from + `_id BIGINT,
-		` + to + `_id BIGINT,
-		FOREIGN KEY (` + from + `_id) REFERENCES ` + from + `(id),
-		FOREIGN KEY (` + to + `_id) REFERENCES ` + to + `(id)
+		` + from + ` ` + from_type + `,
+		` + to + ` ` + to_type
+	for _, fk := range joinForeignKeys(r) {
+		query += `,
+		` + foreignKeyDefinition(fk)
+	}
+	query += `
 	)`
-	_, err := db.Exec(query)
-	return err
+	return query
 }
 
+// Link two models in the join table of the relation between them, see joinLink.
+//
+// Deprecated: use AddRelated with the name of the relation.
 func (db *Database) InsertFK(from, to Model) error {
-	query := `INSERT INTO ` + from.TableName() + `_` + to.TableName() + ` (` + from.TableName() + `_id, ` + to.TableName() + `_id) VALUES (?, ?)`
-	_, err := db.Exec(query, GetValue(from, "id"), GetValue(to, "id"))
+	l := db.joinLink(from, to)
+	_, to_pk := primaryKey(db.meta(to), to)
+	_, err := db.Exec(`INSERT INTO `+l.table+` (`+l.from_column+`, `+l.to_column+`) VALUES (?, ?)`, l.from_pk, to_pk)
 	return err
 }
 
+// Unlink two models in the join table of the relation between them, see joinLink.
+//
+// Deprecated: use RemoveRelated with the name of the relation.
 func (db *Database) DeleteFK(from, to Model) error {
-	query := `DELETE FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + from.TableName() + `_id = ? AND ` + to.TableName() + `_id = ?`
-	_, err := db.Exec(query, GetValue(from, "id"), GetValue(to, "id"))
+	l := db.joinLink(from, to)
+	_, to_pk := primaryKey(db.meta(to), to)
+	_, err := db.Exec(`DELETE FROM `+l.table+` WHERE `+l.from_column+` = ? AND `+l.to_column+` = ?`, l.from_pk, to_pk)
 	return err
 }
 
 func (db *Database) DropFKTable(from, to string) error {
-	query := `DROP TABLE IF EXISTS ` + from + `_` + to
-	_, err := db.Exec(query)
+	return db.DropRelationTable(Relation{From: from, To: to})
+}
+
+// Drop the join table of a relation.
+func (db *Database) DropRelationTable(r Relation) error {
+	_, err := db.Exec(dropRelationTableQuery(r))
 	return err
 }
 
+// Get the query to drop the join table of a relation.
+func dropRelationTableQuery(r Relation) string {
+	return `DROP TABLE IF EXISTS ` + r.joinTable()
+}
+
+// Select the models linked to the from model in the join table of the relation between them, see joinLink.
 func (db *Database) SelectFK(from, to Model) (ModelSet, error) {
-	query := `SELECT * FROM ` + to.TableName() + ` WHERE id IN (SELECT ` + to.TableName() + `_id FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + from.TableName() + `_id = ?)`
-	rows, err := db.Query(query, GetValue(from, "id"))
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	qs := ScanRows(rows, to, nil)
-	return qs, nil
+	l := db.joinLink(from, to)
+	return db.selectLinked(to, `SELECT `+l.to_column+` FROM `+l.table+` WHERE `+l.from_column+` = ?`, l.from_pk)
 }
 
+// Select the models linked to the to model in the join table of the relation between them, see joinLink.
 func (db *Database) SelectFKReverse(from, to Model) (ModelSet, error) {
-	query := `SELECT * FROM ` + from.TableName() + ` WHERE id IN (SELECT ` + from.TableName() + `_id FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + to.TableName() + `_id = ?)`
-	rows, err := db.Query(query, GetValue(to, "id"))
+	l := db.joinLink(from, to)
+	_, to_pk := primaryKey(db.meta(to), to)
+	return db.selectLinked(from, `SELECT `+l.from_column+` FROM `+l.table+` WHERE `+l.to_column+` = ?`, to_pk)
+}
+
+// Select the models whose primary key is in the result of a subquery.
+func (db *Database) selectLinked(model Model, subquery string, args ...any) (ModelSet, error) {
+	meta := db.meta(model)
+	rows, err := db.Query(`SELECT * FROM `+model.TableName()+` WHERE `+meta.PrimaryColumn()+` IN (`+subquery+`)`, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	qs := ScanRows(rows, from, nil)
-	return qs, nil
+	return scanRows(context.Background(), db, meta, rows, model, nil), nil
 }
 
 func (db *Database) AlterOneToOne(from, to string) error {
-	query := `ALTER TABLE ` + from + `_` + to + ` ADD UNIQUE (` + from + `_id, ` + to + `_id)`
-	_, err := db.Exec(query)
+	return db.alterOneToOne(Relation{From: from, To: to})
+}
+
+func (db *Database) alterOneToOne(r Relation) error {
+	_, err := db.Exec(oneToOneQuery(r))
 	return err
 }
 
+// Get the query to add the unique constraint of a one to one relation to its join table.
+func oneToOneQuery(r Relation) string {
+	from, to := r.joinColumns()
+	return `ALTER TABLE ` + r.joinTable() + ` ADD UNIQUE (` + from + `, ` + to + `)`
+}
+
+// Link two models of a one to one relation, see InsertFK.
+//
+// Deprecated: use AddRelated with the name of the relation.
 func (db *Database) InsertOneToOne(from, to Model) error {
-	query := `INSERT INTO ` + from.TableName() + `_` + to.TableName() + ` (` + from.TableName() + `_id, ` + to.TableName() + `_id) VALUES (?, ?)`
-	_, err := db.Exec(query, GetValue(from, "id"), GetValue(to, "id"))
-	return err
+	return db.InsertFK(from, to)
 }
 
+// Select the model linked to the from model of a one to one relation, see SelectFK.
+// Returns nil if no model is linked.
 func (db *Database) SelectOneToOne(from, to Model) (Model, error) {
-	query := `SELECT * FROM ` + to.TableName() + ` WHERE id IN (SELECT ` + to.TableName() + `_id FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + from.TableName() + `_id = ?)`
-	rows, err := db.Query(query, GetValue(from, "id"))
-	if err != nil {
+	qs, err := db.SelectFK(from, to)
+	if err != nil || len(qs) == 0 {
 		return nil, err
 	}
-	defer rows.Close()
-	qs := ScanRows(rows, to, nil)
-	if len(qs) == 0 {
-		return nil, nil
-	}
 	return qs[0], nil
 }
 
+// Select the model linked to the to model of a one to one relation, see SelectFKReverse.
+// Returns nil if no model is linked.
 func (db *Database) GetOneToOneReverse(from, to Model) (Model, error) {
-	query := `SELECT * FROM ` + from.TableName() + ` WHERE id IN (SELECT ` + from.TableName() + `_id FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + to.TableName() + `_id = ?)`
-	rows, err := db.Query(query, GetValue(to, "id"))
-	if err != nil {
+	qs, err := db.SelectFKReverse(from, to)
+	if err != nil || len(qs) == 0 {
 		return nil, err
 	}
-	defer rows.Close()
-	qs := ScanRows(rows, from, nil)
-	if len(qs) == 0 {
-		return nil, nil
-	}
 	return qs[0], nil
 }
 
+// Unlink two models of a one to one relation, see DeleteFK.
+//
+// Deprecated: use RemoveRelated with the name of the relation.
 func (db *Database) DeleteOneToOne(from, to Model) error {
-	query := `DELETE FROM ` + from.TableName() + `_` + to.TableName() + ` WHERE ` + from.TableName() + `_id = ? AND ` + to.TableName() + `_id = ?`
-	_, err := db.Exec(query, GetValue(from, "id"), GetValue(to, "id"))
-	return err
+	return db.DeleteFK(from, to)
 }
 
 func (db *Database) AlterDropOneToOne(from, to string) error {
-	query := `ALTER TABLE ` + from + `_` + to + ` DROP FOREIGN KEY ` + from + `_` + to + `_ibfk_1`
-	_, err := db.Exec(query)
+	return db.alterDropOneToOne(Relation{From: from, To: to})
+}
+
+func (db *Database) alterDropOneToOne(r Relation) error {
+	from, _ := r.joinColumns()
+	return db.DropForeignKey(r.joinTable(), from)
+}
+
+// ThroughResult is a related model, together with the through model which links it.
+type ThroughResult struct {
+	// The related model
+	Model Model
+	// The through model, holding the extra fields of the relation
+	Through Model
+}
+
+// Get the through model of a many to many relation between two models.
+// The relation must be declared on the from model with the THROUGH tag,
+// the through model must be registered with the database.
+// Example:
+//
+//	type User struct {
+//	    ID        int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+//	    Rel_teams []*Team `simpledb:"RELTYPE:M2M,THROUGH:membership"`
+//	}
+//
+//	type Membership struct {
+//	    ID     int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+//	    UserID int64  `simpledb:"COLUMN:user_id"`
+//	    TeamID int64  `simpledb:"COLUMN:teams_id"`
+//	    Role   string `simpledb:"LENGTH:32"`
+//	}
+//
+// The name of the relation must be given if the from model has multiple relations with a through model to the to model.
+// Returns the through model, and the columns pointing to the from and the to model.
+func (db *Database) throughModel(from, to Model, name ...string) (Model, string, string, error) {
+	meta := db.meta(from)
+	f, err := relationTo(meta, to.TableName(), isThroughRelation, name...)
+	if err != nil {
+		return nil, "", "", err
+	}
+	through, ok := db.ModelByTable(f.Tags.Through())
+	if !ok {
+		return nil, "", "", errors.New("through model " + f.Tags.Through() + " is not registered")
+	}
+	_, from_column, to_column := joinTable(meta, f)
+	return through, from_column, to_column, nil
+}
+
+// Insert a relation between two models using a through model.
+// The through model holds the extra fields of the relation,
+// the foreign key columns are set before inserting it.
+// The name of the relation is needed if there are multiple relations between the models, see throughModel.
+func (db *Database) InsertThrough(from, to, through Model, name ...string) error {
+	_, from_column, to_column, err := db.throughModel(from, to, name...)
+	if err != nil {
+		return err
+	}
+	_, from_pk := primaryKey(db.meta(from), from)
+	_, to_pk := primaryKey(db.meta(to), to)
+	setValue(db.meta(through), through, from_column, from_pk)
+	setValue(db.meta(through), through, to_column, to_pk)
+	return db.InsertModel(through)
+}
+
+// Select the related models of a many to many relation using a through model.
+// Returns the related models, together with the through models which link them.
+func (db *Database) SelectThrough(from, to Model, name ...string) ([]ThroughResult, error) {
+	through, from_column, to_column, err := db.throughModel(from, to, name...)
+	if err != nil {
+		return nil, err
+	}
+	_, from_pk := primaryKey(db.meta(from), from)
+	rows, err := db.Query(`SELECT * FROM `+through.TableName()+` WHERE `+from_column+` = ?`, from_pk)
+	if err != nil {
+		return nil, err
+	}
+	links := scanRows(context.Background(), db, db.meta(through), rows, through, nil)
+	rows.Close()
+	if len(links) == 0 {
+		return []ThroughResult{}, nil
+	}
+	ids := make([]any, len(links))
+	for i, link := range links {
+		ids[i] = getValue(db.meta(link), link, to_column)
+	}
+	pk, _ := primaryKey(db.meta(to), to)
+	related, err := db.selectIn(to, pk, ids)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ThroughResult, 0, len(links))
+	for i, link := range links {
+		if model, ok := related[linkKey(ids[i])]; ok {
+			results = append(results, ThroughResult{Model: model, Through: link})
+		}
+	}
+	return results, nil
+}
+
+// Delete the relation between two models using a through model.
+func (db *Database) DeleteThrough(from, to Model, name ...string) error {
+	through, from_column, to_column, err := db.throughModel(from, to, name...)
+	if err != nil {
+		return err
+	}
+	_, from_pk := primaryKey(db.meta(from), from)
+	_, to_pk := primaryKey(db.meta(to), to)
+	query := `DELETE FROM ` + through.TableName() + ` WHERE ` + from_column + ` = ? AND ` + to_column + ` = ?`
+	_, err = db.Exec(query, from_pk, to_pk)
 	return err
 }
+
+// Select models where the column is in a list of values.
+// The models are returned by the value of the column, normalized with linkKey.
+// Returns the models by the string representation of their column value.
+func (db *Database) selectIn(model Model, column string, values []any) (map[string]Model, error) {
+	filters := Filters{}.Add(column, IN, values)
+	f_query, args := filters.Query(true)
+	rows, err := db.Query(`SELECT * FROM `+model.TableName()+f_query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := db.meta(model)
+	models := make(map[string]Model)
+	for _, m := range scanRows(context.Background(), db, meta, rows, model, nil) {
+		models[linkKey(getValue(meta, m, column))] = m
+	}
+	return models, nil
+}
@@ -0,0 +1,225 @@
+package simpledb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// A many to many or one to one relation of a model, stored in a join table.
+type link struct {
+	table       string
+	from_column string
+	to_column   string
+	from_pk     any
+}
+
+// Get the join table of a relation of a model by name.
+// Relations with a through model must be managed with InsertThrough and DeleteThrough.
+func (db *Database) relationLink(from Model, name string) (link, error) {
+	meta := db.meta(from)
+	f, ok := meta.Relation(name)
+	if !ok {
+		return link{}, errors.New("no relation " + name + " on model " + meta.Table)
+	}
+	if t := relationType(f.Tags.RelType()); t != relManyToMany && t != relOneToOne {
+		return link{}, errors.New("relation " + name + " is not stored in a join table")
+	}
+	if f.Tags.Through() != "" {
+		return link{}, errors.New("relation " + name + " has a through model, use InsertThrough and DeleteThrough")
+	}
+	table, from_column, to_column := joinTable(meta, f)
+	_, pk := primaryKey(meta, from)
+	return link{table: table, from_column: from_column, to_column: to_column, from_pk: pk}, nil
+}
+
+// Get the primary keys of the related models.
+func (db *Database) relatedKeys(related []Model) []any {
+	keys := make([]any, 0, len(related))
+	for _, model := range related {
+		_, pk := primaryKey(db.meta(model), model)
+		keys = append(keys, pk)
+	}
+	return keys
+}
+
+// Get the keys of the models linked to the model, as returned by the driver.
+func (db *Database) linkedKeys(ctx context.Context, l link) ([]any, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+l.to_column+` FROM `+l.table+` WHERE `+l.from_column+` = ?`, l.from_pk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []any{}
+	for rows.Next() {
+		var key any
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Normalize a key to compare keys of models with the keys scanned from the database.
+// The driver returns strings as []byte, and the values of driver.Valuer keys are compared.
+func linkKey(key any) string {
+	if valuer, ok := key.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			key = v
+		}
+	}
+	switch v := key.(type) {
+	case []byte:
+		return string(v)
+	case sql.RawBytes:
+		return string(v)
+	}
+	return fmt.Sprint(key)
+}
+
+// A SQL statement with its arguments.
+type Statement struct {
+	Query string
+	Args  []any
+}
+
+// Get the statements to link a model to the keys in a join table.
+// Linked are the keys the model is linked to now, these links are not inserted again.
+// If replace is true, the links to keys which are not in keys are deleted.
+// Keys are compared by their normalized value, see linkKey.
+// Returns the DELETE statement before the INSERT statement, statements without rows are left out.
+func (db *Database) LinkStatements(table, from_column, to_column string, from_pk any, linked, keys []any, replace bool) []Statement {
+	current := map[string]bool{}
+	for _, key := range linked {
+		current[linkKey(key)] = true
+	}
+	wanted := map[string]bool{}
+	values := []any{}
+	for _, key := range keys {
+		k := linkKey(key)
+		if wanted[k] {
+			continue
+		}
+		wanted[k] = true
+		if !current[k] {
+			values = append(values, from_pk, key)
+		}
+	}
+	statements := []Statement{}
+	if replace {
+		removed := []any{}
+		for _, key := range linked {
+			if !wanted[linkKey(key)] {
+				removed = append(removed, key)
+			}
+		}
+		if len(removed) > 0 {
+			statements = append(statements, deleteLinks(table, from_column, to_column, from_pk, removed))
+		}
+	}
+	if len(values) > 0 {
+		statements = append(statements, Statement{
+			Query: db.BulkInsertQuery(table, []string{from_column, to_column}, len(values)/2),
+			Args:  values,
+		})
+	}
+	return statements
+}
+
+// Get the statement to delete the links of a model to the keys.
+func deleteLinks(table, from_column, to_column string, from_pk any, keys []any) Statement {
+	f_query, args := Filters{}.Add(from_column, EQ, from_pk).Add(to_column, IN, keys).Query(true)
+	return Statement{Query: `DELETE FROM ` + table + f_query, Args: args}
+}
+
+// Execute statements in order.
+func (db *Database) execStatements(ctx context.Context, statements []Statement) error {
+	for _, s := range statements {
+		if _, err := db.ExecContext(ctx, s.Query, s.Args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Link models to a model through a relation stored in a join table.
+// Models which are already linked are skipped, the others are inserted in a single statement.
+// Example:
+//
+//	err := db.AddRelated(ctx, post, "tags", []Model{tag1, tag2})
+func (db *Database) AddRelated(ctx context.Context, from Model, name string, related []Model) error {
+	l, err := db.relationLink(from, name)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(ctx, func(tx *Database) error {
+		return tx.addLinks(ctx, l, db.relatedKeys(related))
+	})
+}
+
+// Insert the links which do not exist yet.
+func (db *Database) addLinks(ctx context.Context, l link, keys []any) error {
+	linked, err := db.linkedKeys(ctx, l)
+	if err != nil {
+		return err
+	}
+	return db.execStatements(ctx, db.LinkStatements(l.table, l.from_column, l.to_column, l.from_pk, linked, keys, false))
+}
+
+// Unlink models from a model, in a single statement.
+func (db *Database) RemoveRelated(ctx context.Context, from Model, name string, related []Model) error {
+	l, err := db.relationLink(from, name)
+	if err != nil {
+		return err
+	}
+	return db.removeLinks(ctx, l, db.relatedKeys(related))
+}
+
+// Delete the links to the keys.
+func (db *Database) removeLinks(ctx context.Context, l link, keys []any) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return db.execStatements(ctx, []Statement{deleteLinks(l.table, l.from_column, l.to_column, l.from_pk, keys)})
+}
+
+// Unlink all models from a model.
+func (db *Database) ClearRelated(ctx context.Context, from Model, name string) error {
+	l, err := db.relationLink(from, name)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `DELETE FROM `+l.table+` WHERE `+l.from_column+` = ?`, l.from_pk)
+	return err
+}
+
+// Count the models linked to a model.
+func (db *Database) CountRelated(ctx context.Context, from Model, name string) (int, error) {
+	l, err := db.relationLink(from, name)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+l.table+` WHERE `+l.from_column+` = ?`, l.from_pk).Scan(&count)
+	return count, err
+}
+
+// Replace the models linked to a model.
+// The current links are compared to the models, missing links are inserted,
+// and links to other models are deleted, in a single transaction.
+func (db *Database) SetRelated(ctx context.Context, from Model, name string, related []Model) error {
+	l, err := db.relationLink(from, name)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(ctx, func(tx *Database) error {
+		linked, err := tx.linkedKeys(ctx, l)
+		if err != nil {
+			return err
+		}
+		return tx.execStatements(ctx, tx.LinkStatements(l.table, l.from_column, l.to_column, l.from_pk, linked, db.relatedKeys(related), true))
+	})
+}
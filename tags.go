@@ -37,6 +37,38 @@ func (t ModelTags) Length() int {
 	return n
 }
 
+// Get the precision of a DECIMAL column.
+func (t ModelTags) Precision() int {
+	n, err := strconv.Atoi(t.Get("PRECISION"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Get the scale of a DECIMAL column.
+func (t ModelTags) Scale() int {
+	n, err := strconv.Atoi(t.Get("SCALE"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Get the allowed values of an ENUM or SET column, separated by a pipe.
+// Example:
+//
+//	type Post struct {
+//	    Status string `simpledb:"TYPE:ENUM,VALUES:draft|published"`
+//	}
+func (t ModelTags) Values() []string {
+	v := t.Get("VALUES")
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, "|")
+}
+
 // Verify if column is nullable.
 func (t ModelTags) Nullable() bool {
 	v := t.Get("NULLABLE")
@@ -47,6 +79,17 @@ func (t ModelTags) Nullable() bool {
 	return b
 }
 
+// Verify if an integer column is unsigned.
+// Unsigned golang integers are always unsigned.
+func (t ModelTags) Unsigned() bool {
+	v := t.Get("UNSIGNED")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
 // Verify if column is unique.
 func (t ModelTags) Unique() bool {
 	v := t.Get("UNIQUE")
@@ -87,6 +130,28 @@ func (t ModelTags) Auto() bool {
 	return b
 }
 
+// AutoNowAdd is a special tag that indicates that the column should be set
+// to the current time when the model is inserted.
+func (t ModelTags) AutoNowAdd() bool {
+	v := t.Get("AUTO_NOW_ADD")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// AutoNow is a special tag that indicates that the column should be set
+// to the current time every time the model is inserted or updated.
+func (t ModelTags) AutoNow() bool {
+	v := t.Get("AUTO_NOW")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
 // Default is a special tag that indicates that the column has a default value.
 // This default value is however very limited, and can only be set in the
 // model tags.
@@ -94,6 +159,142 @@ func (t ModelTags) Default() string {
 	return t.Get("DEFAULT")
 }
 
+// Verify if a field should be stored as JSON.
+// Used for slices, maps and structs.
+func (t ModelTags) JSON() bool {
+	v := t.Get("JSON")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// Verify if a field should be encrypted at rest.
+// Only string and []byte fields can be encrypted.
+func (t ModelTags) Encrypted() bool {
+	v := t.Get("ENCRYPTED")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// Get the name of the blind index column of an encrypted field.
+// The blind index allows for equality lookups on encrypted fields.
+// Example:
+//
+//	type User struct {
+//	    Email string `simpledb:"ENCRYPTED:true,LENGTH:255,BLIND_INDEX:email_index"`
+//	}
+func (t ModelTags) BlindIndex() string {
+	return t.Get("BLIND_INDEX")
+}
+
+// Get the column name from the tag.
+// This overrides the naming strategy of the database.
+// Example:
+//
+//	type User struct {
+//	    Created time.Time `simpledb:"COLUMN:created_at"`
+//	}
+func (t ModelTags) Column() string {
+	return t.Get("COLUMN")
+}
+
+// Verify if a named struct field should be flattened into the model.
+// Anonymous (embedded) structs are always flattened.
+func (t ModelTags) Embed() bool {
+	v := t.Get("EMBED")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// Get the column prefix for the fields of an embedded struct.
+// Example:
+//
+//	type Order struct {
+//	    Billing  Address `simpledb:"EMBED:true,PREFIX:billing_"`
+//	    Shipping Address `simpledb:"EMBED:true,PREFIX:shipping_"`
+//	}
+func (t ModelTags) Prefix() string {
+	return t.Get("PREFIX")
+}
+
+// Get the name of the key generator for a primary key.
+// See RegisterKeyGenerator.
+func (t ModelTags) Generate() string {
+	return t.Get("GENERATE")
+}
+
+// Get the table name of the through model of a many to many relation.
+// The through model is used as the join table, and can hold extra fields.
+func (t ModelTags) Through() string {
+	return t.Get("THROUGH")
+}
+
+// Get the referential action of a relation for when the referenced row is deleted.
+// One of CASCADE, SET NULL, RESTRICT or NO ACTION.
+// Example:
+//
+//	Rel_customer *Customer `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,ONDELETE:SET NULL"`
+func (t ModelTags) OnDelete() string {
+	return referentialAction(t.Get("ONDELETE"))
+}
+
+// Get the referential action of a relation for when the referenced key is updated.
+// One of CASCADE, SET NULL, RESTRICT or NO ACTION.
+func (t ModelTags) OnUpdate() string {
+	return referentialAction(t.Get("ONUPDATE"))
+}
+
+// Normalize a referential action, underscores may be used instead of spaces. (SET_NULL)
+// Panics if the action is not supported.
+func referentialAction(action string) string {
+	action = strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(action, "_", " ")))
+	switch action {
+	case "", "CASCADE", "SET NULL", "RESTRICT", "NO ACTION":
+		return action
+	}
+	panic("invalid referential action: " + action)
+}
+
+// Get the table a related field points to.
+// Defaults to the table name of the related model.
+// Example:
+//
+//	Rel_author *User `simpledb:"RELTYPE:BELONGSTO,TO:user"`
+func (t ModelTags) To() string {
+	return t.Get("TO")
+}
+
+// Get the name of the reverse side of a relation.
+// The related models can be queried from the target model with this name, see Database.Related.
+// Example:
+//
+//	Rel_author *User `simpledb:"RELTYPE:BELONGSTO,RELATED_NAME:books"`
+func (t ModelTags) RelatedName() string {
+	return t.Get("RELATED_NAME")
+}
+
+// Verify if a self-referential belongs-to relation points to the parent in a tree.
+// The tree helpers of the database can be used on models with a tree relation, see Database.Children.
+// Example:
+//
+//	Rel_parent *Category `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,TREE:true"`
+func (t ModelTags) Tree() bool {
+	v := t.Get("TREE")
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false
+	}
+	return b
+}
+
 // Get the relation type from the tag.
 func (t ModelTags) RelType() string {
 	return t.Get("RELTYPE")
@@ -128,13 +329,38 @@ func TagValid(field reflect.StructField) bool {
 // Split tags into lists of [Key:Value, Key:Value, ...] pairs.
 func TagValues(field reflect.StructField) []string {
 	tag := field.Tag.Get(TAG)
-	return strings.Split(tag, ",")
+	return splitTag(tag)
+}
+
+// Split a tag on commas.
+// A comma escaped with a backslash is part of the value, the backslash is removed.
+// The backslash must itself be escaped in the struct tag.
+// Example:
+//
+//	Code string `simpledb:"LENGTH:3,REGEX:^\\d{1\\,3}$"` // REGEX: ^\d{1,3}$
+func splitTag(tag string) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			part.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(tag[i])
+		}
+	}
+	return append(parts, part.String())
 }
 
 // Generate a map from the list of [Key:Value, Key:Value, ...] pairs.
+// Commas in values must be escaped, see splitTag.
 func TagMap(field reflect.StructField) ModelTags {
 	tag := field.Tag.Get(TAG)
-	tags := strings.Split(tag, ",")
+	tags := splitTag(tag)
 	tagmap := make(map[string]string)
 	for _, v := range tags {
 		if v == "+" {
@@ -142,7 +368,7 @@ func TagMap(field reflect.StructField) ModelTags {
 		}
 		tag := strings.SplitN(v, ":", 2)
 		if len(tag) != 2 {
-			panic("invalid tag")
+			panic("invalid tag " + v + " on field " + field.Name + ", escape commas in values with a backslash")
 		}
 		tagmap[tag[0]] = tag[1]
 	}
@@ -154,18 +380,52 @@ func (t ModelTags) ToColumn(tname, name, typ string) Column {
 	if t.Type() != "" {
 		typ = t.Type()
 	}
+	if t.JSON() && t.Type() == "" {
+		typ = string(JSON)
+	}
+	var length = t.Length()
+	if t.Encrypted() && t.Type() == "" {
+		if length > 0 {
+			// Every character can take up to 4 bytes.
+			typ, length = string(VARBINARY), length*4+encryptionOverhead
+		} else {
+			typ = string(BLOB)
+		}
+	}
+	switch DBType(strings.ToUpper(typ)) {
+	case UUID:
+		typ, length = string(CHAR), 36
+	case UUID_BINARY:
+		typ, length = string(BINARY), 16
+	}
+	var def = t.Default()
+	if t.AutoNow() || t.AutoNowAdd() {
+		if t.Type() == "" {
+			typ = string(DATETIME)
+		}
+		if def == "" {
+			def = "CURRENT_TIMESTAMP"
+			if t.AutoNow() {
+				def += " ON UPDATE CURRENT_TIMESTAMP"
+			}
+		}
+	}
 	return Column{
-		Table:    tname,
-		Name:     name,
-		Type:     DBType(typ),
-		Length:   t.Length(),
-		Nullable: t.Nullable(),
-		Unique:   t.Unique(),
-		Primary:  t.Primary(),
-		Index:    t.Index(),
-		Auto:     t.Auto(),
-		Default:  t.Default(),
-		Raw:      t.Raw(),
-		Tags:     t,
+		Table:     tname,
+		Name:      name,
+		Type:      DBType(typ),
+		Length:    length,
+		Precision: t.Precision(),
+		Scale:     t.Scale(),
+		Values:    t.Values(),
+		Unsigned:  t.Unsigned(),
+		Nullable:  t.Nullable(),
+		Unique:    t.Unique(),
+		Primary:   t.Primary(),
+		Index:     t.Index(),
+		Auto:      t.Auto(),
+		Default:   def,
+		Raw:       t.Raw(),
+		Tags:      t,
 	}
 }
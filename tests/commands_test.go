@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+func TestRunCommand(t *testing.T) {
+	var ctx = context.Background()
+	var dir = t.TempDir()
+	var db = simpledb.NewDatabase()
+	var out strings.Builder
+	if err := db.RunCommand(ctx, []string{"makemigrations", "-dir", dir}, &out); err == nil || !strings.HasPrefix(err.Error(), "no models registered") {
+		t.Error("Expected an error without registered models, got", err)
+	}
+	if err := db.RunCommand(ctx, []string{"sqlmigrate", "-dir", dir}, &out); err == nil || !strings.HasPrefix(err.Error(), "no models registered") {
+		t.Error("Expected an error without registered models, got", err)
+	}
+	db.Register(&Customer{})
+	db.Register(&Order{})
+
+	out.Reset()
+	if err := db.RunCommand(ctx, []string{"makemigrations", "-dir", dir}, &out); err != nil || !strings.HasPrefix(out.String(), "Created migration Migration_") {
+		t.Fatal("Unexpected makemigrations output", out.String(), err)
+	}
+	var name = strings.TrimSpace(strings.TrimPrefix(out.String(), "Created migration "))
+	out.Reset()
+	if err := db.RunCommand(ctx, []string{"makemigrations", "-dir", dir}, &out); err != nil || out.String() != "No changes detected\n" {
+		t.Error("Unexpected makemigrations output", out.String(), err)
+	}
+
+	out.Reset()
+	if err := db.RunCommand(ctx, []string{"sqlmigrate", "-dir", dir, name}, &out); err != nil || !strings.HasPrefix(out.String(), "-- create table customer\nCREATE TABLE customer (") {
+		t.Error("Unexpected sqlmigrate output", out.String(), err)
+	}
+	var path = filepath.Join(t.TempDir(), "migration.sql")
+	if err := db.RunCommand(ctx, []string{"sqlmigrate", "-dir", dir, "-out", path, name}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), "FOREIGN KEY (customer_id)") {
+		t.Error("Unexpected SQL file", string(data), err)
+	}
+
+	if err := db.RunCommand(ctx, []string{"sqlmigrate", "-dir", dir, name, "-out", path}, &out); err == nil || !strings.Contains(err.Error(), "-out must come before the arguments") {
+		t.Error("Expected an error for a flag after the arguments, got", err)
+	}
+	if err := db.RunCommand(ctx, []string{"migrate", "-dir", dir}, &out); err == nil || !strings.HasPrefix(err.Error(), "database credentials are missing") {
+		t.Error("Expected an error for missing credentials, got", err)
+	}
+
+	if err := db.RunCommand(ctx, []string{"unknown"}, &out); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+}
+
+func TestSSLMode(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	db.Host, db.Port, db.Username, db.Database = "localhost", "3306", "user", "app"
+	for mode, expected := range map[string]string{
+		"disable":     "user@tcp(localhost:3306)/app?parseTime=true",
+		"require":     "user@tcp(localhost:3306)/app?parseTime=true&tls=skip-verify",
+		"verify-full": "user@tcp(localhost:3306)/app?parseTime=true&tls=true",
+	} {
+		db.SSL_MODE = mode
+		if dsn := db.DSN(); dsn != expected {
+			t.Error("Expected", expected, "for", mode, "got", dsn)
+		}
+	}
+	db.SSL_MODE = "unknown"
+	if err := db.Connect(); err == nil || !strings.HasPrefix(err.Error(), "unknown ssl mode") {
+		t.Error("Expected an error for an unknown ssl mode, got", err)
+	}
+	if err := db.RunCommand(context.Background(), []string{"dbshell"}, &strings.Builder{}); err == nil || !strings.HasPrefix(err.Error(), "unknown ssl mode") {
+		t.Error("Expected dbshell to report the unknown ssl mode, got", err)
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+type SecretModel struct {
+	ID    int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Email string  `simpledb:"ENCRYPTED:true,LENGTH:255,BLIND_INDEX:email_index"`
+	Notes *string `simpledb:"ENCRYPTED:true,NULLABLE:true"`
+}
+
+func (m *SecretModel) TableName() string {
+	return "secret_model"
+}
+
+func newKeys() *simpledb.StaticKeys {
+	return &simpledb.StaticKeys{
+		Current: "v1",
+		Keys: map[string][]byte{
+			"v1": bytes.Repeat([]byte("a"), 32),
+			"v2": bytes.Repeat([]byte("b"), 32),
+		},
+		IndexKey: bytes.Repeat([]byte("i"), 32),
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	var keys = newKeys()
+	var enc = &simpledb.AESGCM{Keys: keys}
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Current = "v2"
+	rotated, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][]byte{ciphertext, rotated} {
+		plaintext, err := enc.Decrypt(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(plaintext) != "secret" {
+			t.Error("Expected secret, got", string(plaintext))
+		}
+	}
+	delete(keys.Keys, "v1")
+	if _, err := enc.Decrypt(ciphertext); err == nil {
+		t.Error("Expected an error when the key was removed")
+	}
+}
+
+func TestEncryptedFields(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	db.KeyProvider = newKeys()
+	db.Register(&SecretModel{})
+
+	var expected = map[string]string{
+		"id":          "id BIGINT NOT NULL PRIMARY KEY AUTO_INCREMENT",
+		"email":       "email VARBINARY(1081) NOT NULL",
+		"email_index": "email_index BINARY(32) NOT NULL",
+		"notes":       "notes BLOB NULL",
+	}
+	var cols = simpledb.MigrationColumns(&SecretModel{})
+	if len(cols) != len(expected) {
+		t.Error("Expected", len(expected), "columns, got", len(cols))
+	}
+	for _, col := range cols {
+		if col.String() != expected[col.Name] {
+			t.Error("Expected", expected[col.Name], "got", col.String())
+		}
+	}
+
+	var model = &SecretModel{Email: "john@example.com"}
+	if _, _, err := simpledb.ModelValues(model); err == nil {
+		t.Error("Expected an error for encrypted fields without a database")
+	}
+	columns, values, err := db.ModelValues(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(columns, ",") != "id,email,notes,email_index" {
+		t.Error("Unexpected columns", columns)
+	}
+	if bytes.Contains(values[1].([]byte), []byte("john@example.com")) {
+		t.Error("Expected the email to be encrypted")
+	}
+	if values[2] != nil {
+		t.Error("Expected nil notes to be stored as NULL")
+	}
+	index, _ := db.BlindIndex("john@example.com")
+	if !bytes.Equal(values[3].([]byte), index) {
+		t.Error("Unexpected blind index")
+	}
+
+	var query, args = simpledb.NewQuerySet(db, model).All().Where("email", simpledb.EQ, "john@example.com").Query()
+	if !strings.Contains(query, "WHERE email_index = ?") || !bytes.Equal(args[0].([]byte), index) {
+		t.Error("Expected the lookup to use the blind index, got", query)
+	}
+
+	var qs = simpledb.NewQuerySet(db, model).All().Where("email", simpledb.IN, []string{"a@example.com", "b@example.com"})
+	if query, args = qs.Query(); qs.Err() != nil || len(args) != 2 || !strings.Contains(query, "WHERE email_index IN (?, ?)") {
+		t.Error("Expected an IN lookup on the blind index, got", query, qs.Err())
+	}
+	qs = simpledb.NewQuerySet(db, model).All().Where("email", simpledb.LIKE, "%john%")
+	if _, err := qs.Exec(); err == nil || !strings.Contains(err.Error(), "only equality lookups") {
+		t.Error("Expected an error for a LIKE lookup on an encrypted field, got", err)
+	}
+	qs = simpledb.NewQuerySet(db, model).All().Where("notes", simpledb.EQ, "x")
+	if _, err := qs.Exec(); err == nil || !strings.Contains(err.Error(), "without a blind index") {
+		t.Error("Expected an error for a lookup without a blind index, got", err)
+	}
+
+	// Every database uses its own keys, regardless of where the model was registered.
+	var other = simpledb.NewDatabase()
+	other.KeyProvider = &simpledb.StaticKeys{Current: "v1", Keys: map[string][]byte{"v1": bytes.Repeat([]byte("c"), 32)}, IndexKey: bytes.Repeat([]byte("o"), 32)}
+	other.Register(&SecretModel{})
+	_, args = simpledb.NewQuerySet(db, model).All().Where("email", simpledb.EQ, "john@example.com").Query()
+	if !bytes.Equal(args[0].([]byte), index) {
+		t.Error("Expected the blind index of the queried database")
+	}
+	var filters, err2 = simpledb.Filters{}.Add("email", simpledb.EQ, "john@example.com").Resolve(simpledb.NewDatabase(), model)
+	if err2 == nil {
+		t.Error("Expected an error for a database without keys, got", filters)
+	}
+}
@@ -0,0 +1,300 @@
+package tests
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+type TimestampModel struct {
+	ID      int64      `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Created time.Time  `simpledb:"AUTO_NOW_ADD:true"`
+	Updated *time.Time `simpledb:"AUTO_NOW:true,NULLABLE:true"`
+}
+
+func (m *TimestampModel) TableName() string {
+	return "timestamp_model"
+}
+
+func TestTimestamps(t *testing.T) {
+	var now = time.Date(2022, 11, 15, 12, 0, 0, 0, time.UTC)
+	var model = &TimestampModel{}
+	simpledb.SetTimestamps(model, now, true)
+	if !model.Created.Equal(now) {
+		t.Error("Expected created to be", now, "got", model.Created)
+	}
+	if model.Updated == nil || !model.Updated.Equal(now) {
+		t.Error("Expected updated to be", now, "got", model.Updated)
+	}
+	var later = now.Add(time.Hour)
+	simpledb.SetTimestamps(model, later, false)
+	if !model.Created.Equal(now) {
+		t.Error("Created should not change on update, got", model.Created)
+	}
+	if !model.Updated.Equal(later) {
+		t.Error("Expected updated to be", later, "got", model.Updated)
+	}
+	for _, col := range simpledb.MigrationColumns(model) {
+		switch col.Name {
+		case "created":
+			if col.String() != "created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP" {
+				t.Error("Unexpected column: ", col.String())
+			}
+		case "updated":
+			if col.String() != "updated DATETIME NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" {
+				t.Error("Unexpected column: ", col.String())
+			}
+		}
+	}
+}
+
+type NamedModel struct {
+	ID        int64     `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	UserID    int64     `simpledb:"INDEX:true"`
+	CreatedAt time.Time `simpledb:"AUTO_NOW_ADD:true"`
+	Legacy    string    `simpledb:"COLUMN:legacy_name,LENGTH:255"`
+}
+
+func (m *NamedModel) TableName() string {
+	return "named_model"
+}
+
+func TestNaming(t *testing.T) {
+	for in, out := range map[string]string{
+		"ID":        "id",
+		"Name":      "name",
+		"CreatedAt": "created_at",
+		"UserID":    "user_id",
+		"HTTPPort":  "http_port",
+		"Rel_model": "rel_model",
+	} {
+		if s := simpledb.ToSnakeCase(in); s != out {
+			t.Error("Expected", out, "got", s)
+		}
+	}
+	var snake = simpledb.NewDatabase()
+	var lower = simpledb.NewDatabase()
+	lower.NamingStrategy = simpledb.LowerCase
+	snake.Register(&NamedModel{})
+	lower.Register(&NamedModel{})
+
+	// The package level helpers use the default naming strategy, whichever database the model was registered with.
+	var expected = []string{"id", "user_id", "created_at", "legacy_name"}
+	for _, cols := range [][]string{simpledb.Columns(&NamedModel{}), snake.Columns(&NamedModel{})} {
+		if strings.Join(cols, ",") != strings.Join(expected, ",") {
+			t.Error("Expected", expected, "got", cols)
+		}
+	}
+	expected = []string{"id", "userid", "createdat", "legacy_name"}
+	if cols := lower.Columns(&NamedModel{}); strings.Join(cols, ",") != strings.Join(expected, ",") {
+		t.Error("Expected", expected, "got", cols)
+	}
+	var model = &NamedModel{Legacy: "legacy", UserID: 3}
+	if simpledb.GetValue(model, "Legacy") != "legacy" || simpledb.GetValue(model, "legacy_name") != "legacy" {
+		t.Error("Expected to get the value by field name and column name")
+	}
+	if simpledb.GetValue(model, "user_id") != int64(3) || lower.GetValue(model, "userid") != int64(3) || lower.GetValue(model, "user_id") != nil {
+		t.Error("Expected to get the value by the column name of the naming strategy")
+	}
+	var query, _ = simpledb.NewQuerySet(snake, model).All().Where("UserID", simpledb.EQ, 1).Query()
+	if !strings.Contains(query, "WHERE user_id = ?") {
+		t.Error("Expected the filter to use the snake case column name, got", query)
+	}
+	query, _ = simpledb.NewQuerySet(lower, model).All().Where("UserID", simpledb.EQ, 1).Query()
+	if !strings.Contains(query, "WHERE userid = ?") {
+		t.Error("Expected the filter to use the lowercase column name, got", query)
+	}
+	query, _ = simpledb.NewQuerySet(lower, model).All().Where("Legacy", simpledb.EQ, "x").Query()
+	if !strings.Contains(query, "WHERE legacy_name = ?") {
+		t.Error("Expected the filter to use the COLUMN tag, got", query)
+	}
+}
+
+type BaseModel struct {
+	ID        int64     `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	CreatedAt time.Time `simpledb:"AUTO_NOW_ADD:true"`
+	UpdatedAt time.Time `simpledb:"AUTO_NOW:true"`
+}
+
+type Address struct {
+	Street string `simpledb:"LENGTH:255"`
+	City   string `simpledb:"LENGTH:64"`
+}
+
+type EmbeddedModel struct {
+	BaseModel
+	Name     string  `simpledb:"LENGTH:255"`
+	Billing  Address `simpledb:"EMBED:true,PREFIX:billing_"`
+	Shipping Address `simpledb:"EMBED:true,PREFIX:shipping_"`
+}
+
+func (m *EmbeddedModel) TableName() string {
+	return "embedded_model"
+}
+
+func TestEmbedded(t *testing.T) {
+	var cols = simpledb.Columns(&EmbeddedModel{})
+	var expected = []string{"id", "created_at", "updated_at", "name", "billing_street", "billing_city", "shipping_street", "shipping_city"}
+	if strings.Join(cols, ",") != strings.Join(expected, ",") {
+		t.Error("Expected", expected, "got", cols)
+	}
+	var model = &EmbeddedModel{}
+	simpledb.SetValue(model, "id", int64(5))
+	simpledb.SetValue(model, "shipping_city", "Amsterdam")
+	if model.ID != 5 || model.Shipping.City != "Amsterdam" {
+		t.Error("Expected values to be set on the embedded structs, got", model)
+	}
+	if simpledb.GetValue(model, "shipping_city") != "Amsterdam" {
+		t.Error("Expected to get the value from the embedded struct")
+	}
+	var meta = simpledb.GetMeta(model)
+	if meta.Primary == nil || meta.Primary.Name != "ID" {
+		t.Error("Expected the primary key to be found in the embedded struct")
+	}
+}
+
+type PointerEmbeddedModel struct {
+	*BaseModel
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *PointerEmbeddedModel) TableName() string {
+	return "pointer_embedded_model"
+}
+
+func TestEmbeddedPointer(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "BaseModel") {
+			t.Error("Expected a panic for an embedded struct pointer, got", r)
+		}
+	}()
+	simpledb.NewDatabase().Register(&PointerEmbeddedModel{})
+}
+
+type Money int64
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+func (m Money) ColumnType() simpledb.DBType {
+	return simpledb.BIGINT
+}
+
+type TypedModel struct {
+	ID       uint64         `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Nickname *string        `simpledb:"LENGTH:64"`
+	Email    sql.NullString `simpledb:"LENGTH:255"`
+	Age      uint8          `simpledb:"DEFAULT:0"`
+	Timeout  time.Duration  `simpledb:"DEFAULT:0"`
+	Price    Money          `simpledb:"DEFAULT:0"`
+	Data     []byte         `simpledb:"NULLABLE:true"`
+}
+
+func (m *TypedModel) TableName() string {
+	return "typed_model"
+}
+
+func TestColumnTypes(t *testing.T) {
+	var expected = map[string]string{
+		"id":       "id BIGINT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT",
+		"nickname": "nickname VARCHAR(64) NULL",
+		"email":    "email VARCHAR(255) NULL",
+		"age":      "age TINYINT UNSIGNED NOT NULL DEFAULT 0",
+		"timeout":  "timeout BIGINT NOT NULL DEFAULT 0",
+		"price":    "price BIGINT NOT NULL DEFAULT 0",
+		"data":     "data BLOB NULL",
+	}
+	for _, col := range simpledb.MigrationColumns(&TypedModel{}) {
+		if col.String() != expected[col.Name] {
+			t.Error("Expected", expected[col.Name], "got", col.String())
+		}
+	}
+	var model = &TypedModel{}
+	if v := simpledb.GetValue(model, "nickname"); v != nil {
+		t.Error("Expected nil for a nil pointer, got", v)
+	}
+	var columns, types = simpledb.ColumnsWithTypes(model)
+	if strings.Join(columns, ",") != "id,nickname,email,age,timeout,price,data" ||
+		strings.Join(types, ",") != "uint64,*string,sql.NullString,uint8,time.Duration,tests.Money,[]uint8" {
+		t.Error("Unexpected columns with types", columns, types)
+	}
+}
+
+type RichModel struct {
+	ID      string    `simpledb:"TYPE:UUID,PRIMARY:true"`
+	Token   []byte    `simpledb:"TYPE:UUID_BINARY"`
+	Price   float64   `simpledb:"TYPE:DECIMAL,PRECISION:10,SCALE:2"`
+	Born    time.Time `simpledb:"TYPE:DATE"`
+	Body    string    `simpledb:"TYPE:LONGTEXT"`
+	Status  string    `simpledb:"TYPE:ENUM,VALUES:draft|published|it's"`
+	Options string    `simpledb:"TYPE:SET,VALUES:a|b,NULLABLE:true"`
+}
+
+func (m *RichModel) TableName() string {
+	return "rich_model"
+}
+
+func TestRichColumnTypes(t *testing.T) {
+	var expected = map[string]string{
+		"id":      "id CHAR(36) NOT NULL PRIMARY KEY",
+		"token":   "token BINARY(16) NOT NULL",
+		"price":   "price DECIMAL(10,2) NOT NULL",
+		"born":    "born DATE NOT NULL",
+		"body":    "body LONGTEXT NOT NULL",
+		"status":  "status ENUM('draft','published','it''s') NOT NULL",
+		"options": "options SET('a','b') NULL",
+	}
+	for _, col := range simpledb.MigrationColumns(&RichModel{}) {
+		if col.String() != expected[col.Name] {
+			t.Error("Expected", expected[col.Name], "got", col.String())
+		}
+	}
+	var model = &RichModel{Status: "deleted"}
+	var errs simpledb.ValidationErrors
+	if !errors.As(mDB.Validate(model), &errs) || !errs.Has("status") {
+		t.Error("Expected an error for the ENUM value")
+	}
+}
+
+type Settings struct {
+	Theme string `json:"theme"`
+	Size  int    `json:"size"`
+}
+
+type JSONModel struct {
+	ID       int64             `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Settings Settings          `simpledb:"JSON:true"`
+	Tags     []string          `simpledb:"JSON:true,NULLABLE:true"`
+	Meta     map[string]string `simpledb:"JSON:true"`
+}
+
+func (m *JSONModel) TableName() string {
+	return "json_model"
+}
+
+func TestJSONFields(t *testing.T) {
+	var model = &JSONModel{Settings: Settings{Theme: "dark", Size: 12}}
+	values, err := simpledb.DBValues(model, simpledb.Columns(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[1] != `{"theme":"dark","size":12}` || values[2] != nil || values[3] != "null" {
+		t.Error("Unexpected JSON values", values)
+	}
+	for _, col := range simpledb.MigrationColumns(model) {
+		if col.Name == "settings" && col.String() != "settings JSON NOT NULL" {
+			t.Error("Unexpected column", col.String())
+		}
+	}
+	var query, args = simpledb.NewQuerySet(mDB, model).All().Where("settings__theme", simpledb.EQ, "dark").Query()
+	if !strings.Contains(query, "WHERE JSON_EXTRACT(settings, '$.theme') = ?") || args[0] != "dark" {
+		t.Error("Unexpected query", query)
+	}
+}
@@ -0,0 +1,375 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+	"github.com/Nigel2392/simplelog"
+)
+
+func TestMakeMigrations(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var migration = simpledb.NewMigration(db)
+	migration.Directory = t.TempDir()
+	migration.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}})
+	name, err := migration.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(name, "Migration_") {
+		t.Error("Expected a migration to be written, got", name)
+	}
+	if name, err := migration.Make(); err != nil || name != "" {
+		t.Error("Expected no migration for unchanged models, got", name, err)
+	}
+	files, err := migration.ReadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != name || len(files[0].Checksum) != 64 || len(files[0].Migration.Tables) != 2 || len(files[0].Migration.Operations) == 0 {
+		t.Error("Unexpected migration files", files)
+	}
+
+	// Migrations made right after each other get separate files, in the order they were made.
+	var changed = simpledb.NewMigration(db)
+	changed.Directory = migration.Directory
+	changed.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}, &User{}})
+	second, err := changed.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err = migration.ReadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == name || len(files) != 2 || files[0].Name != name || files[1].Name != second {
+		t.Error("Expected a second migration file after the first, got", name, second, files)
+	}
+
+	var table = simpledb.ModelToTable(&simpledb.AppliedMigration{})
+	if table.Name != simpledb.MigrationTable || !strings.Contains(table.String(), "name VARCHAR(255) NOT NULL UNIQUE") {
+		t.Error("Unexpected migration table", table.String())
+	}
+}
+
+func TestMigrationOperations(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var before = simpledb.NewMigration(db)
+	before.CreateFromModels([]simpledb.Model{&Customer{}})
+	var after = simpledb.NewMigration(db)
+	after.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}})
+
+	var ops = after.Diff(*before)
+	if len(ops) != 2 {
+		t.Fatal("Expected 2 operations, got", ops)
+	}
+	if !strings.HasPrefix(ops[0].Forward[0], "CREATE TABLE orders") || ops[0].Reverse[0] != "DROP TABLE orders" || !ops[0].LossyReverse {
+		t.Error("Unexpected create table operation", ops[0])
+	}
+	if !strings.Contains(ops[1].Forward[0], "FOREIGN KEY (customer_id) REFERENCES customer(id)") ||
+		ops[1].Reverse[0] != "ALTER TABLE orders DROP FOREIGN KEY fk_orders_customer_id" {
+		t.Error("Unexpected create relation operation", ops[1])
+	}
+
+	var columns = simpledb.ModelToTable(&Customer{})
+	var dropped = columns
+	dropped.Columns = columns.Columns[:1]
+	ops = simpledb.Migration{Database: db, Tables: []simpledb.Table{dropped}}.Diff(simpledb.Migration{Tables: []simpledb.Table{columns}})
+	if len(ops) != 1 || !ops[0].Destructive || ops[0].Forward[0] != "ALTER TABLE customer DROP COLUMN name" ||
+		ops[0].Reverse[0] != "ALTER TABLE customer ADD COLUMN name VARCHAR(255) NOT NULL" {
+		t.Error("Unexpected drop column operation", ops)
+	}
+
+	var changed = simpledb.NewMigration(db)
+	changed.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}})
+	changed.Tables[1].Relations[0].OnDelete = "CASCADE"
+	if changes := changed.Changes(*after); changes.Empty() || len(changes.DifferentRelations) != 1 {
+		t.Error("Expected the different referential actions to be reported, got", changes)
+	}
+	if missing_tables, _, _, _, removed_tables, _, _ := changed.Validate(*after); len(missing_tables)+len(removed_tables) != 0 {
+		t.Error("Expected no missing or removed tables, got", missing_tables, removed_tables)
+	}
+
+	// The join table referencing a removed table is dropped before the table, and created again after it.
+	var shelves = simpledb.NewMigration(db)
+	shelves.CreateFromModels([]simpledb.Model{&Publisher{}, &Shelf{}})
+	var publishers = simpledb.NewMigration(db)
+	publishers.CreateFromModels([]simpledb.Model{&Publisher{}})
+	ops = publishers.Diff(*shelves)
+	if len(ops) != 2 || ops[0].Description != "drop relation shelf.publishers" || ops[1].Forward[0] != "DROP TABLE shelf" {
+		t.Error("Unexpected drop table operations", ops)
+	}
+}
+
+func TestMigrationPlan(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var migration = simpledb.NewMigration(db)
+	migration.Directory = t.TempDir()
+	migration.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}})
+	plan, err := migration.PlanChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 3 || len(plan.Statements()) != 3 || plan[0].Description != "create table customer" {
+		t.Fatal("Unexpected plan", plan)
+	}
+	var path = filepath.Join(t.TempDir(), "plan.sql")
+	if err := plan.WriteSQL(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "-- create table customer\nCREATE TABLE customer (") ||
+		!strings.Contains(string(data), "ON DELETE SET NULL ON UPDATE CASCADE;\n") {
+		t.Error("Unexpected SQL", string(data))
+	}
+	if files, _ := migration.ReadMigrations(); len(files) != 0 {
+		t.Error("Expected no migration files to be written, got", files)
+	}
+}
+
+func TestMigrationExistsIn(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var migration = simpledb.NewMigration(db)
+	migration.CreateFromModels([]simpledb.Model{&Customer{}, &Order{}})
+	var schema = map[string][]string{
+		"customer": simpledb.Columns(&Customer{}),
+		"orders":   simpledb.Columns(&Order{}),
+		"other":    {"id"},
+	}
+	if !migration.ExistsIn(schema) {
+		t.Error("Expected the migration to exist in", schema)
+	}
+	schema["orders"] = schema["orders"][:1]
+	if migration.ExistsIn(schema) {
+		t.Error("Expected a missing column to be reported")
+	}
+	delete(schema, "orders")
+	if migration.ExistsIn(schema) {
+		t.Error("Expected a missing table to be reported")
+	}
+	if (simpledb.Migration{}).ExistsIn(schema) {
+		t.Error("Expected an empty migration not to exist")
+	}
+}
+
+func TestApplyBaseline(t *testing.T) {
+	var ctx = context.Background()
+	var migration = simpledb.NewMigration(mDB)
+	migration.Directory = t.TempDir()
+	migration.CreateFromModels([]simpledb.Model{&Customer{}})
+	name, err := migration.Make()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database migrated before the history table existed.
+	for _, query := range []string{
+		"DROP TABLE IF EXISTS " + simpledb.MigrationTable,
+		"DROP TABLE IF EXISTS customer",
+		simpledb.ModelToTable(&Customer{}).String(),
+	} {
+		if _, err := mDB.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		mDB.ExecContext(ctx, "DROP TABLE IF EXISTS customer")
+		mDB.ExecContext(ctx, "DELETE FROM "+simpledb.MigrationTable+" WHERE name = ?", name)
+	})
+
+	applied, err := migration.Apply(ctx)
+	if err != nil || applied != 0 {
+		t.Fatal("Expected the existing migration to be recorded without applying it, got", applied, err)
+	}
+	history, err := mDB.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Name != name {
+		t.Error("Expected the existing migration to be recorded, got", history)
+	}
+	if applied, err := migration.Apply(ctx); err != nil || applied != 0 {
+		t.Error("Expected no migrations to apply, got", applied, err)
+	}
+}
+
+// Logger which records the info and warning messages.
+type recordingLogger struct {
+	simplelog.Logger
+	infos    []string
+	warnings []string
+}
+
+func (l *recordingLogger) Info(msg ...any) {
+	l.infos = append(l.infos, fmt.Sprint(msg...))
+}
+
+func (l *recordingLogger) Warning(msg ...any) {
+	l.warnings = append(l.warnings, fmt.Sprint(msg...))
+}
+
+// Write a migration file with the given operations.
+func writeMigration(t *testing.T, dir, name string, operations ...simpledb.Operation) {
+	data, err := json.Marshal(simpledb.Migration{Operations: operations})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	var ctx = context.Background()
+	var logger = &recordingLogger{Logger: mDB.Logger}
+	var previous = mDB.Logger
+	mDB.Logger = logger
+	var migration = simpledb.NewMigration(mDB)
+	migration.Directory = t.TempDir()
+	t.Cleanup(func() {
+		mDB.Logger = previous
+		mDB.ExecContext(ctx, "DROP TABLE IF EXISTS rollback_two")
+		mDB.ExecContext(ctx, "DROP TABLE IF EXISTS rollback_one")
+	})
+	if _, err := mDB.AppliedMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mDB.ExecContext(ctx, "DELETE FROM "+simpledb.MigrationTable); err != nil {
+		t.Fatal(err)
+	}
+
+	const first, second, third = "Migration_2020-01-01-00-00-00", "Migration_2020-01-02-00-00-00", "Migration_2020-01-03-00-00-00"
+	writeMigration(t, migration.Directory, first, simpledb.Operation{
+		Description:  "create table rollback_one",
+		Forward:      []string{"CREATE TABLE rollback_one (id INT NOT NULL PRIMARY KEY)"},
+		Reverse:      []string{"DROP TABLE rollback_one"},
+		LossyReverse: true,
+	})
+	// Dropping rollback_one before rollback_two fails on the foreign key.
+	writeMigration(t, migration.Directory, second, simpledb.Operation{
+		Description: "create table rollback_two",
+		Forward:     []string{"CREATE TABLE rollback_two (id INT NOT NULL PRIMARY KEY, one_id INT, FOREIGN KEY (one_id) REFERENCES rollback_one(id))"},
+		Reverse:     []string{"DROP TABLE rollback_two"},
+	})
+	writeMigration(t, migration.Directory, third, simpledb.Operation{
+		Description: "irreversible",
+		Forward:     []string{"SELECT 1"},
+	})
+	if applied, err := migration.Apply(ctx); err != nil || applied != 3 {
+		t.Fatal("Expected 3 migrations to be applied, got", applied, err)
+	}
+
+	// The third migration cannot be undone, nothing is rolled back.
+	if count, err := migration.Rollback(ctx, first); err == nil || count != 0 {
+		t.Fatal("Expected an irreversible migration to stop the rollback, got", count, err)
+	}
+	if history, err := mDB.AppliedMigrations(ctx); err != nil || len(history) != 3 {
+		t.Fatal("Expected no migrations to be rolled back, got", history, err)
+	}
+	if _, err := mDB.ExecContext(ctx, "DELETE FROM "+simpledb.MigrationTable+" WHERE name = ?", third); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(migration.Directory, third+".json")); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.infos, logger.warnings = nil, nil
+	count, err := migration.Rollback(ctx, "")
+	if err != nil || count != 2 {
+		t.Fatal("Expected 2 migrations to be rolled back, got", count, err)
+	}
+	var rolled_back []string
+	for _, info := range logger.infos {
+		if strings.HasPrefix(info, "MIGRATION: rolling back ") {
+			rolled_back = append(rolled_back, strings.TrimPrefix(info, "MIGRATION: rolling back "))
+		}
+	}
+	if strings.Join(rolled_back, ",") != second+","+first {
+		t.Error("Expected the newest migration to be rolled back first, got", rolled_back)
+	}
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "create table rollback_one in "+first) {
+		t.Error("Expected a warning for the lossy reverse, got", logger.warnings)
+	}
+	if history, err := mDB.AppliedMigrations(ctx); err != nil || len(history) != 0 {
+		t.Error("Expected the history rows to be removed, got", history, err)
+	}
+	if mDB.LatestMigration != nil {
+		t.Error("Expected no latest migration, got", mDB.LatestMigration)
+	}
+}
+
+func TestMigrationPlanUnapplied(t *testing.T) {
+	var ctx = context.Background()
+	var migration = simpledb.NewMigration(mDB)
+	migration.Directory = t.TempDir()
+	migration.CreateFromModels([]simpledb.Model{&Customer{}})
+	const name = "Migration_2020-01-01-00-00-00"
+	writeMigration(t, migration.Directory, name, simpledb.Operation{
+		Description: "create table plan_one",
+		Forward:     []string{"CREATE TABLE plan_one (id INT NOT NULL PRIMARY KEY)"},
+		Reverse:     []string{"DROP TABLE plan_one"},
+	})
+	if _, err := mDB.AppliedMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mDB.ExecContext(ctx, "DELETE FROM "+simpledb.MigrationTable+" WHERE name = ?", name); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unapplied file comes before the model changes.
+	plan, err := migration.PlanContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Description != "create table plan_one" || plan[1].Description != "create table customer" {
+		t.Error("Unexpected plan", plan)
+	}
+	if changes, err := migration.PlanChanges(); err != nil || len(changes) != 1 || changes[0].Description != "create table customer" {
+		t.Error("Unexpected model changes", changes, err)
+	}
+}
+
+func TestApplyConcurrently(t *testing.T) {
+	var ctx = context.Background()
+	var migration = simpledb.NewMigration(mDB)
+	migration.Directory = t.TempDir()
+	const name = "Migration_2020-01-01-00-00-00"
+	writeMigration(t, migration.Directory, name, simpledb.Operation{
+		Description: "create table concurrent_one",
+		Forward:     []string{"CREATE TABLE concurrent_one (id INT NOT NULL PRIMARY KEY)"},
+		Reverse:     []string{"DROP TABLE concurrent_one"},
+	})
+	t.Cleanup(func() {
+		mDB.ExecContext(ctx, "DROP TABLE IF EXISTS concurrent_one")
+		mDB.ExecContext(ctx, "DELETE FROM "+simpledb.MigrationTable+" WHERE name = ?", name)
+	})
+
+	// The second process waits for the lock, and finds the migration applied.
+	var results = make(chan error, 2)
+	var counts = make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			applied, err := migration.Apply(ctx)
+			counts <- applied
+			results <- err
+		}()
+	}
+	var total int
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Error(err)
+		}
+		total += <-counts
+	}
+	if total != 1 {
+		t.Error("Expected the migration to be applied once, got", total)
+	}
+}
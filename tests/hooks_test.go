@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+var errEmptyName = errors.New("name cannot be empty")
+
+type HookModel struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *HookModel) TableName() string {
+	return "hook_model"
+}
+
+func (m *HookModel) BeforeSave(ctx context.Context) error {
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func TestBeforeSaveAborts(t *testing.T) {
+	var model = &HookModel{Name: "   "}
+	err := mDB.InsertModelContext(context.Background(), model)
+	if !errors.Is(err, errEmptyName) {
+		t.Error("Expected", errEmptyName, "got", err)
+	}
+	_, err = mDB.UpdateModel(model)
+	if !errors.Is(err, errEmptyName) {
+		t.Error("Expected", errEmptyName, "got", err)
+	}
+}
+
+var (
+	errRollback  = errors.New("roll back")
+	errAfterSave = errors.New("after save failed")
+	hookCalls    []string
+)
+
+type AuditModel struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *AuditModel) TableName() string {
+	return "audit_model"
+}
+
+func (m *AuditModel) BeforeSave(ctx context.Context) error {
+	if m.Name == "" {
+		return errEmptyName
+	}
+	return nil
+}
+
+func (m *AuditModel) AfterInsert(ctx context.Context) error {
+	hookCalls = append(hookCalls, "AfterInsert")
+	return nil
+}
+
+func (m *AuditModel) AfterUpdate(ctx context.Context) error {
+	hookCalls = append(hookCalls, "AfterUpdate")
+	return nil
+}
+
+func (m *AuditModel) AfterSave(ctx context.Context) error {
+	hookCalls = append(hookCalls, "AfterSave")
+	if m.Name == "fail" {
+		return errAfterSave
+	}
+	return nil
+}
+
+func (m *AuditModel) AfterDelete(ctx context.Context) error {
+	hookCalls = append(hookCalls, "AfterDelete")
+	return nil
+}
+
+// Create the table of a model for a test, if it does not exist.
+func createTable(t *testing.T, model simpledb.Model) {
+	t.Helper()
+	query := strings.Replace(simpledb.ModelToTable(model).String(), "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)
+	if _, err := mDB.Exec(query); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Count the rows of the audit_model table with a name.
+func countAudit(t *testing.T, name string) int {
+	t.Helper()
+	var count int
+	if err := mDB.QueryRow(`SELECT COUNT(*) FROM audit_model WHERE name = ?`, name).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestAfterHooksSkippedOnAbort(t *testing.T) {
+	hookCalls = nil
+	if err := mDB.InsertModel(&AuditModel{}); !errors.Is(err, errEmptyName) {
+		t.Error("Expected", errEmptyName, "got", err)
+	}
+	if _, err := mDB.UpdateModel(&AuditModel{ID: 1}); !errors.Is(err, errEmptyName) {
+		t.Error("Expected", errEmptyName, "got", err)
+	}
+	if len(hookCalls) != 0 {
+		t.Error("Expected no After hooks to run, got", hookCalls)
+	}
+}
+
+func TestAfterHooks(t *testing.T) {
+	createTable(t, &AuditModel{})
+	var model = &AuditModel{Name: "hooks"}
+	var expect = func(calls ...string) {
+		t.Helper()
+		if strings.Join(hookCalls, ",") != strings.Join(calls, ",") {
+			t.Error("Expected hooks", calls, "got", hookCalls)
+		}
+		hookCalls = nil
+	}
+	hookCalls = nil
+	if err := mDB.InsertModel(model); err != nil {
+		t.Fatal(err)
+	}
+	expect("AfterInsert", "AfterSave")
+	if _, err := mDB.UpdateModel(model); err != nil {
+		t.Fatal(err)
+	}
+	expect("AfterUpdate", "AfterSave")
+	if err := mDB.DeleteModel(model); err != nil {
+		t.Fatal(err)
+	}
+	expect("AfterDelete")
+}
+
+func TestTransactionRollback(t *testing.T) {
+	createTable(t, &AuditModel{})
+	var ctx = context.Background()
+	err := mDB.Transaction(ctx, func(tx *simpledb.Database) error {
+		if err := tx.InsertModelContext(ctx, &AuditModel{Name: "rolled back"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Error("Expected", errRollback, "got", err)
+	}
+	if count := countAudit(t, "rolled back"); count != 0 {
+		t.Error("Expected the insert to be rolled back, found", count, "rows")
+	}
+
+	// An error from an After hook rolls back the transaction as well.
+	err = mDB.Transaction(ctx, func(tx *simpledb.Database) error {
+		return tx.InsertModelContext(ctx, &AuditModel{Name: "fail"})
+	})
+	if !errors.Is(err, errAfterSave) {
+		t.Error("Expected", errAfterSave, "got", err)
+	}
+	if count := countAudit(t, "fail"); count != 0 {
+		t.Error("Expected the insert to be rolled back, found", count, "rows")
+	}
+}
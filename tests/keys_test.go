@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+func TestUUID(t *testing.T) {
+	var re = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([47])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	v4, err := simpledb.NewUUIDv4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := re.FindStringSubmatch(v4.String()); m == nil || m[1] != "4" {
+		t.Error("Invalid UUIDv4", v4.String())
+	}
+	var v7s = make([]string, 100)
+	for i := range v7s {
+		v7, err := simpledb.NewUUIDv7()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v7s[i] = v7.String()
+		if m := re.FindStringSubmatch(v7s[i]); m == nil || m[1] != "7" {
+			t.Error("Invalid UUIDv7", v7s[i])
+		}
+	}
+}
+
+func TestULID(t *testing.T) {
+	ulid, err := simpledb.NewULID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`).MatchString(ulid) {
+		t.Error("Invalid ULID", ulid)
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	var s = simpledb.NewSnowflake(1)
+	var last int64
+	for i := 0; i < 10000; i++ {
+		id := s.Next()
+		if id <= last {
+			t.Fatal("Expected snowflake IDs to increase, got", id, "after", last)
+		}
+		if (id>>12)&0x3ff != 1 {
+			t.Fatal("Expected node 1 in", id)
+		}
+		last = id
+	}
+}
+
+func TestBulkInsertQuery(t *testing.T) {
+	var query = mDB.BulkInsertQuery("test_model", []string{"id", "name"}, 3)
+	if query != "INSERT INTO test_model (id, name) VALUES (?, ?), (?, ?), (?, ?)" {
+		t.Error("Unexpected query", query)
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+func TestGetMeta(t *testing.T) {
+	var wg sync.WaitGroup
+	var metas = make([]*simpledb.ModelMeta, 20)
+	for i := range metas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			metas[i] = simpledb.GetMeta(&ModelOne{})
+		}(i)
+	}
+	wg.Wait()
+	for _, meta := range metas {
+		if meta != metas[0] {
+			t.Fatal("Expected the metadata to be cached")
+		}
+	}
+	var meta = metas[0]
+	if meta.Table != "model_one" {
+		t.Error("Expected table model_one, got", meta.Table)
+	}
+	if meta.Primary == nil || meta.Primary.Column != "id" {
+		t.Error("Expected primary key id, got", meta.Primary)
+	}
+	if len(meta.Columns) != 2 || len(meta.Relations) != 1 {
+		t.Error("Expected 2 columns and 1 relation, got", len(meta.Columns), len(meta.Relations))
+	}
+}
+
+// /////////////////////////////////////////////////////////////////
+//
+// # BENCHMARKS
+//
+// /////////////////////////////////////////////////////////////////
+
+// The reflection based lookup used before the metadata was cached.
+func reflectGetValue(model simpledb.Model, column string) any {
+	kind := reflect.TypeOf(model).Elem()
+	for i := 0; i < kind.NumField(); i++ {
+		f_kind := kind.Field(i)
+		if !simpledb.TagValid(f_kind) {
+			continue
+		}
+		if strings.EqualFold(f_kind.Name, column) {
+			if f_kind.Type.Kind() == reflect.Ptr {
+				return reflect.ValueOf(model).Elem().Field(i).Elem().Interface()
+			}
+			return reflect.ValueOf(model).Elem().Field(i).Interface()
+		}
+	}
+	return nil
+}
+
+// The reflection based columns used before the metadata was cached.
+func reflectColumns(model any) []string {
+	kind := reflect.TypeOf(model).Elem()
+	columns := []string{}
+	for i := 0; i < kind.NumField(); i++ {
+		f := kind.Field(i)
+		if !simpledb.TagValid(f) || strings.HasPrefix(strings.ToLower(f.Name), "rel_") {
+			continue
+		}
+		simpledb.TagMap(f)
+		columns = append(columns, strings.ToLower(f.Name))
+	}
+	return columns
+}
+
+var benchModel = &ValidatedModel{Name: "John", Age: 30, Status: "draft"}
+
+func BenchmarkGetValueReflect(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, col := range []string{"id", "name", "age", "small", "status"} {
+			_ = reflectGetValue(benchModel, col)
+		}
+	}
+}
+
+func BenchmarkGetValueMeta(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, col := range []string{"id", "name", "age", "small", "status"} {
+			_ = simpledb.GetValue(benchModel, col)
+		}
+	}
+}
+
+func BenchmarkColumnsReflect(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = reflectColumns(benchModel)
+	}
+}
+
+func BenchmarkColumnsMeta(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = simpledb.Columns(benchModel)
+	}
+}
@@ -0,0 +1,521 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+type Customer struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *Customer) TableName() string {
+	return "customer"
+}
+
+type Order struct {
+	ID           int64     `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Total        float64   `simpledb:"DEFAULT:0"`
+	Rel_customer *Customer `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,ONDELETE:SET_NULL,ONUPDATE:cascade"`
+}
+
+func (m *Order) TableName() string {
+	return "orders"
+}
+
+func TestBelongsTo(t *testing.T) {
+	var order = &Order{Rel_customer: &Customer{ID: 3}}
+	var cols = simpledb.Columns(order)
+	if strings.Join(cols, ",") != "id,total,customer_id" {
+		t.Error("Unexpected columns", cols)
+	}
+	if v := simpledb.GetValue(order, "customer_id"); v != int64(3) {
+		t.Error("Expected customer_id 3, got", v)
+	}
+	simpledb.SetValue(order, "customer_id", int64(5))
+	if order.Rel_customer == nil || order.Rel_customer.ID != 5 {
+		t.Error("Expected the customer to be set to 5, got", order.Rel_customer)
+	}
+	simpledb.SetValue(order, "customer_id", nil)
+	if order.Rel_customer != nil || simpledb.GetValue(order, "customer_id") != nil {
+		t.Error("Expected the customer to be cleared")
+	}
+
+	var migration = simpledb.MigrationColumns(order)
+	if c := migration[len(migration)-1]; c.String() != "customer_id BIGINT NULL" {
+		t.Error("Unexpected foreign key column", c.String())
+	}
+	var relations = simpledb.MigrationRelations(order)
+	if len(relations) != 1 || relations[0].Column != "customer_id" || relations[0].References != "id" || relations[0].To != "customer" {
+		t.Error("Unexpected relations", relations)
+	}
+	if relations[0].OnDelete != "SET NULL" || relations[0].OnUpdate != "CASCADE" {
+		t.Error("Unexpected referential actions", relations[0].OnDelete, relations[0].OnUpdate)
+	}
+}
+
+type Team struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *Team) TableName() string {
+	return "teams"
+}
+
+type Member struct {
+	ID        int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name      string  `simpledb:"LENGTH:255"`
+	Rel_teams []*Team `simpledb:"RELTYPE:M2M,THROUGH:membership"`
+}
+
+func (m *Member) TableName() string {
+	return "member"
+}
+
+type Membership struct {
+	ID       int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	MemberID int64  `simpledb:"COLUMN:member_id"`
+	TeamID   int64  `simpledb:"COLUMN:teams_id"`
+	Role     string `simpledb:"LENGTH:32"`
+}
+
+func (m *Membership) TableName() string {
+	return "membership"
+}
+
+func TestThrough(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	db.Register(&Team{})
+	db.Register(&Member{})
+	var relations = simpledb.MigrationRelations(&Member{})
+	if len(relations) != 1 || relations[0].Through != "membership" || relations[0].To != "teams" {
+		t.Error("Unexpected relations", relations)
+	}
+	if err := db.InsertThrough(&Member{ID: 1}, &Team{ID: 1}, &Membership{}); err == nil {
+		t.Error("Expected an error for an unregistered through model")
+	}
+	db.Register(&Membership{})
+	if _, ok := db.ModelByTable("membership"); !ok {
+		t.Error("Expected the through model to be registered")
+	}
+}
+
+type Coach struct {
+	ID           int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Rel_coached  []*Team `simpledb:"RELTYPE:M2M,THROUGH:coaching"`
+	Rel_assisted []*Team `simpledb:"RELTYPE:M2M,THROUGH:assisting"`
+}
+
+func (m *Coach) TableName() string {
+	return "coach"
+}
+
+func TestThroughByName(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var err = db.InsertThrough(&Coach{ID: 1}, &Team{ID: 1}, &Membership{})
+	if err == nil || !strings.Contains(err.Error(), "multiple relations") {
+		t.Error("Expected an error for multiple relations without a name, got", err)
+	}
+	err = db.InsertThrough(&Coach{ID: 1}, &Team{ID: 1}, &Membership{}, "assisted")
+	if err == nil || !strings.Contains(err.Error(), "assisting") {
+		t.Error("Expected the through model of the named relation, got", err)
+	}
+	err = db.DeleteThrough(&Coach{ID: 1}, &Team{ID: 1}, "members")
+	if err == nil || !strings.Contains(err.Error(), "no matching relation") {
+		t.Error("Expected an error for an unknown relation, got", err)
+	}
+}
+
+func TestSelectRelated(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var qs = simpledb.NewQuerySet(db, &Order{}).SelectRelated("customer").All().Where("id", simpledb.EQ, 1)
+	var query, _ = qs.Query()
+	var expected = "SELECT orders.id, orders.total, orders.customer_id, rel__customer.id AS customer__id, rel__customer.name AS customer__name FROM orders LEFT JOIN customer AS rel__customer ON rel__customer.id = orders.customer_id WHERE orders.id = ?"
+	if !strings.HasPrefix(query, expected) {
+		t.Error("Unexpected query", query)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for selecting a to-many relation")
+		}
+	}()
+	simpledb.NewQuerySet(db, &Member{}).SelectRelated("teams")
+}
+
+type Author struct {
+	ID        int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name      string  `simpledb:"LENGTH:255"`
+	Rel_books []*Book `simpledb:"RELTYPE:OTM,ONDELETE:CASCADE"`
+}
+
+func (m *Author) TableName() string {
+	return "author"
+}
+
+type Book struct {
+	ID    int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Title string `simpledb:"LENGTH:255"`
+}
+
+func (m *Book) TableName() string {
+	return "books"
+}
+
+func TestOneToMany(t *testing.T) {
+	var relations = simpledb.MigrationRelations(&Author{})
+	if len(relations) != 1 || relations[0].To != "books" || relations[0].Column != "author_id" || relations[0].References != "id" || relations[0].OnDelete != "CASCADE" {
+		t.Error("Unexpected relations", relations)
+	}
+	var db = simpledb.NewDatabase()
+	var qs, err = db.Related(context.Background(), &Author{ID: 2}, "books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var query, values = qs.Where("title", simpledb.EQ, "Go").Query()
+	if !strings.HasPrefix(query, "SELECT * FROM books WHERE author_id = ? AND title = ?") || values[0] != int64(2) {
+		t.Error("Unexpected query", query, values)
+	}
+	if _, err := db.Related(context.Background(), &Member{ID: 1}, "teams"); err == nil {
+		t.Error("Expected an error for a relation which is not one to many")
+	}
+}
+
+type Publisher struct {
+	ID            string      `simpledb:"LENGTH:36,PRIMARY:true"`
+	Rel_books     []*Book     `simpledb:"RELTYPE:OTM"`
+	Rel_magazines []*Magazine `simpledb:"RELTYPE:OTM,COLUMN:publisher"`
+}
+
+func (m *Publisher) TableName() string {
+	return "publisher"
+}
+
+type Magazine struct {
+	ID        int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Publisher string `simpledb:"LENGTH:36"`
+}
+
+func (m *Magazine) TableName() string {
+	return "magazine"
+}
+
+func TestOneToManyColumns(t *testing.T) {
+	var relations = simpledb.MigrationRelations(&Publisher{})
+	if len(relations) != 2 {
+		t.Fatal("Expected 2 relations, got", relations)
+	}
+	var ops = simpledb.Migration{Database: simpledb.NewDatabase(), Tables: []simpledb.Table{simpledb.ModelToTable(&Publisher{})}}.Diff(simpledb.Migration{})
+	if len(ops) != 3 {
+		t.Fatal("Expected 3 operations, got", ops)
+	}
+	// The foreign key column has the type of the primary key of the parent.
+	if !strings.HasPrefix(ops[1].Forward[0], "ALTER TABLE books ADD COLUMN publisher_id VARCHAR(36) NULL, ADD CONSTRAINT fk_books_publisher_id") {
+		t.Error("Unexpected query", ops[1].Forward)
+	}
+	// The column declared by the child model is kept, only the constraint is added.
+	if ops[2].Forward[0] != "ALTER TABLE magazine ADD CONSTRAINT fk_magazine_publisher FOREIGN KEY (publisher) REFERENCES publisher(id)" ||
+		ops[2].Reverse[0] != "ALTER TABLE magazine DROP FOREIGN KEY fk_magazine_publisher" || len(ops[2].Reverse) != 1 || ops[2].LossyReverse {
+		t.Error("Unexpected operation", ops[2])
+	}
+}
+
+type Shelf struct {
+	Code           string       `simpledb:"LENGTH:16,PRIMARY:true"`
+	Rel_publishers []*Publisher `simpledb:"RELTYPE:M2M"`
+}
+
+func (m *Shelf) TableName() string {
+	return "shelf"
+}
+
+func TestJoinTableColumns(t *testing.T) {
+	var ops = simpledb.Migration{Database: simpledb.NewDatabase(), Tables: []simpledb.Table{simpledb.ModelToTable(&Shelf{})}}.Diff(simpledb.Migration{})
+	if len(ops) != 2 {
+		t.Fatal("Expected 2 operations, got", ops)
+	}
+	// The join columns have the types of the primary keys, and reference the primary key columns.
+	for _, expected := range []string{
+		"shelf_id VARCHAR(16),",
+		"publisher_id VARCHAR(36),",
+		"FOREIGN KEY (shelf_id) REFERENCES shelf(code)",
+		"FOREIGN KEY (publisher_id) REFERENCES publisher(id)",
+	} {
+		if !strings.Contains(ops[1].Forward[0], expected) {
+			t.Error("Expected", expected, "in query", ops[1].Forward[0])
+		}
+	}
+}
+
+type User struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name string `simpledb:"LENGTH:255"`
+}
+
+func (m *User) TableName() string {
+	return "user"
+}
+
+type Article struct {
+	ID           int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Rel_author   *User   `simpledb:"RELTYPE:BELONGSTO,RELATED_NAME:articles"`
+	Rel_editor   *User   `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,RELATED_NAME:edited"`
+	Rel_readers  []*User `simpledb:"RELTYPE:M2M,RELATED_NAME:read"`
+	Rel_previous *User   `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,TO:user"`
+}
+
+func (m *Article) TableName() string {
+	return "article"
+}
+
+func TestRelationTargets(t *testing.T) {
+	var relations = simpledb.MigrationRelations(&Article{})
+	var expected = []simpledb.Relation{
+		{From: "article", To: "user", Name: "author", Column: "author_id"},
+		{From: "article", To: "user", Name: "editor", Column: "editor_id"},
+		{From: "article", To: "user", Name: "readers"},
+		{From: "article", To: "user", Name: "previous", Column: "previous_id"},
+	}
+	if len(relations) != len(expected) {
+		t.Fatal("Unexpected relations", relations)
+	}
+	for i, r := range relations {
+		if r.From != expected[i].From || r.To != expected[i].To || r.Name != expected[i].Name || r.Column != expected[i].Column {
+			t.Error("Unexpected relation", r)
+		}
+	}
+
+	var db = simpledb.NewDatabase()
+	db.Register(&User{})
+	db.Register(&Article{})
+	var qs, err = db.Related(context.Background(), &User{ID: 1}, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := qs.Query(); !strings.HasPrefix(query, "SELECT * FROM article WHERE editor_id = ?") {
+		t.Error("Unexpected query", query)
+	}
+	qs, err = db.Related(context.Background(), &User{ID: 1}, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := qs.Query(); !strings.HasPrefix(query, "SELECT article.id, article.author_id, article.editor_id, article.previous_id FROM article JOIN article_readers AS link ON link.article_id = article.id WHERE link.user_id = ?") {
+		t.Error("Unexpected query", query)
+	}
+}
+
+func TestSpanningLookups(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var query, values = simpledb.NewQuerySet(db, &Order{}).All().Where("customer__name", simpledb.EQ, "X").Query()
+	if !strings.HasPrefix(query, "SELECT orders.id, orders.total, orders.customer_id FROM orders LEFT JOIN customer AS rel__customer ON rel__customer.id = orders.customer_id WHERE rel__customer.name = ?") || values[0] != "X" {
+		t.Error("Unexpected query", query)
+	}
+	query, _ = simpledb.NewQuerySet(db, &Article{}).All().Where("readers__name", simpledb.EQ, "X").OrderBy("author__name", "ASC").Query()
+	for _, expected := range []string{
+		"SELECT DISTINCT article.id, article.author_id, article.editor_id, article.previous_id, rel__author.name AS rel__author__name FROM article",
+		"LEFT JOIN user AS rel__author ON rel__author.id = article.author_id",
+		"LEFT JOIN article_readers AS rel__readers__link ON rel__readers__link.article_id = article.id",
+		"LEFT JOIN user AS rel__readers ON rel__readers.id = rel__readers__link.user_id",
+		"ORDER BY rel__author.name ASC",
+		"rel__readers.name = ?",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Error("Expected", expected, "in query", query)
+		}
+	}
+
+	// The ORDER BY columns are selected with DISTINCT, also when selecting specific columns.
+	query, _ = simpledb.NewQuerySet(db, &Article{}).Select("id").From().Where("readers__name", simpledb.EQ, "X").OrderBy("author__name", "ASC").OrderBy("editor_id", "DESC").Query()
+	if !strings.HasPrefix(query, "SELECT DISTINCT article.id, rel__author.name AS rel__author__name, article.editor_id AS article__editor_id FROM article") {
+		t.Error("Unexpected query", query)
+	}
+
+	var qs = simpledb.NewQuerySet(db, &Order{}).All().Where("customer__company__name", simpledb.EQ, "X")
+	if query, _ := qs.Query(); query != "" || qs.Err() == nil || !strings.Contains(qs.Err().Error(), "no relation company") {
+		t.Error("Expected an error for an invalid lookup, got", query, qs.Err())
+	}
+	if _, err := qs.Exec(); err == nil {
+		t.Error("Expected Exec to return the error of the lookup")
+	}
+}
+
+type Node struct {
+	ID       int64    `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Settings Settings `simpledb:"JSON:true"`
+	Rel_node *Node    `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true"`
+}
+
+func (m *Node) TableName() string {
+	return "node"
+}
+
+func TestJoinAliases(t *testing.T) {
+	// A relation named like its own table is joined with a prefixed alias,
+	// the columns of the model and of the relation are qualified, JSON lookups included.
+	var db = simpledb.NewDatabase()
+	var query, _ = simpledb.NewQuerySet(db, &Node{}).All().Where("node__settings__theme", simpledb.EQ, "dark").Where("settings__theme", simpledb.EQ, "light").Query()
+	var expected = "SELECT node.id, node.settings, node.node_id FROM node LEFT JOIN node AS rel__node ON rel__node.id = node.node_id WHERE JSON_EXTRACT(rel__node.settings, '$.theme') = ? AND JSON_EXTRACT(node.settings, '$.theme') = ?"
+	if !strings.HasPrefix(query, expected) {
+		t.Error("Unexpected query", query)
+	}
+	query, _ = simpledb.NewQuerySet(db, &Node{}).SelectRelated("node").All().Where("id", simpledb.EQ, 1).Query()
+	expected = "SELECT node.id, node.settings, node.node_id, rel__node.id AS node__id, rel__node.settings AS node__settings, rel__node.node_id AS node__node_id FROM node LEFT JOIN node AS rel__node ON rel__node.id = node.node_id WHERE node.id = ?"
+	if !strings.HasPrefix(query, expected) {
+		t.Error("Unexpected query", query)
+	}
+}
+
+type Category struct {
+	ID          int64       `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name        string      `simpledb:"LENGTH:255"`
+	Rel_parent  *Category   `simpledb:"RELTYPE:BELONGSTO,NULLABLE:true,TREE:true,ONDELETE:CASCADE"`
+	Rel_similar []*Category `simpledb:"RELTYPE:M2M"`
+}
+
+func (m *Category) TableName() string {
+	return "category"
+}
+
+func TestSelfReferential(t *testing.T) {
+	var relations = simpledb.MigrationRelations(&Category{})
+	if len(relations) != 2 || relations[0].To != "category" || relations[0].Column != "parent_id" || relations[1].Name != "similar" {
+		t.Error("Unexpected relations", relations)
+	}
+	var db = simpledb.NewDatabase()
+	var query, _ = simpledb.NewQuerySet(db, &Category{}).All().Where("similar__name", simpledb.EQ, "X").Query()
+	if !strings.Contains(query, "LEFT JOIN category_similar AS rel__similar__link ON rel__similar__link.category_id = category.id LEFT JOIN category AS rel__similar ON rel__similar.id = rel__similar__link.similar_id") {
+		t.Error("Unexpected query", query)
+	}
+	var qs, err = db.Children(context.Background(), &Category{ID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := qs.Query(); !strings.HasPrefix(query, "SELECT * FROM category WHERE parent_id = ?") {
+		t.Error("Unexpected query", query)
+	}
+	if _, err := db.Children(context.Background(), &Customer{ID: 1}); err == nil {
+		t.Error("Expected an error for a model without a tree relation")
+	}
+	if _, err := db.Children(context.Background(), &Folder{ID: 1}); err == nil || !strings.Contains(err.Error(), "must be a pointer to Folder") {
+		t.Error("Expected an error for a tree relation which is not a pointer, got", err)
+	}
+	if err := db.MoveSubtree(context.Background(), &Category{ID: 1}, &Node{ID: 2}); err == nil || !strings.Contains(err.Error(), "parent of type *tests.Node") {
+		t.Error("Expected an error for a parent of another type, got", err)
+	}
+}
+
+type Folder struct {
+	ID         int64 `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Rel_parent int64 `simpledb:"RELTYPE:BELONGSTO,TO:folder,NULLABLE:true,TREE:true"`
+}
+
+func (m *Folder) TableName() string {
+	return "folder"
+}
+
+func TestRelatedErrors(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	var ctx = context.Background()
+	if err := db.AddRelated(ctx, &Order{ID: 1}, "customer", []simpledb.Model{&Customer{ID: 1}}); err == nil {
+		t.Error("Expected an error for a relation which is not stored in a join table")
+	}
+	if err := db.SetRelated(ctx, &Member{ID: 1}, "teams", nil); err == nil {
+		t.Error("Expected an error for a relation with a through model")
+	}
+	if _, err := db.CountRelated(ctx, &Category{ID: 1}, "unknown"); err == nil {
+		t.Error("Expected an error for an unknown relation")
+	}
+}
+
+func TestLinkStatements(t *testing.T) {
+	var db = simpledb.NewDatabase()
+	// The driver returns string keys as []byte.
+	var linked = []any{[]byte("a"), []byte("b")}
+	var statements = db.LinkStatements("post_tags", "post_id", "tags_id", 1, linked, []any{"b", "c", "c"}, true)
+	if len(statements) != 2 {
+		t.Fatal("Expected 2 statements, got", statements)
+	}
+	if statements[0].Query != "DELETE FROM post_tags WHERE post_id = ? AND tags_id IN (?)" ||
+		!reflect.DeepEqual(statements[0].Args, []any{1, []byte("a")}) {
+		t.Error("Unexpected delete statement", statements[0])
+	}
+	if statements[1].Query != "INSERT INTO post_tags (post_id, tags_id) VALUES (?, ?)" ||
+		!reflect.DeepEqual(statements[1].Args, []any{1, "c"}) {
+		t.Error("Unexpected insert statement", statements[1])
+	}
+
+	statements = db.LinkStatements("post_tags", "post_id", "tags_id", 1, linked, []any{"c"}, false)
+	if len(statements) != 1 || !strings.HasPrefix(statements[0].Query, "INSERT INTO") {
+		t.Error("Expected only an insert statement when adding, got", statements)
+	}
+	statements = db.LinkStatements("post_tags", "post_id", "tags_id", 1, []any{int64(2)}, []any{2}, true)
+	if len(statements) != 0 {
+		t.Error("Expected no statements when the links did not change, got", statements)
+	}
+}
+
+type Comment struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Body string `simpledb:"LENGTH:1024"`
+	simpledb.GenericRelation
+	Author simpledb.GenericRelation `simpledb:"EMBED:true,PREFIX:author_"`
+}
+
+func (m *Comment) TableName() string {
+	return "comment"
+}
+
+func TestGenericRelation(t *testing.T) {
+	var cols = simpledb.Columns(&Comment{})
+	if strings.Join(cols, ",") != "id,body,content_table,object_id,author_content_table,author_object_id" {
+		t.Error("Unexpected columns", cols)
+	}
+	var comment = &Comment{}
+	comment.Set(&Order{ID: 7})
+	comment.Author.Set(&User{ID: 2})
+	if comment.ContentTable.String != "orders" || comment.ObjectID.String != "7" || comment.Author.ContentTable.String != "user" {
+		t.Error("Unexpected generic relation", comment.GenericRelation, comment.Author)
+	}
+
+	var db = simpledb.NewDatabase()
+	var ctx = context.Background()
+	var qs, err = db.GenericRelated(ctx, &Comment{}, &Order{ID: 7}, "GenericRelation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, values := qs.Query(); !strings.HasPrefix(query, "SELECT * FROM comment WHERE content_table = ? AND object_id = ?") || values[1] != "7" {
+		t.Error("Unexpected query", query, values)
+	}
+	qs, err = db.GenericRelated(ctx, &Comment{}, &User{}, "Author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := qs.Query(); !strings.HasPrefix(query, "SELECT * FROM comment WHERE author_content_table = ? LIMIT") {
+		t.Error("Unexpected query", query)
+	}
+	if _, err := db.GenericRelated(ctx, &Comment{}, &User{}); err == nil {
+		t.Error("Expected an error for multiple generic relations without a name")
+	}
+	if _, err := db.ResolveGeneric(ctx, comment.GenericRelation); err == nil {
+		t.Error("Expected an error for an unregistered model")
+	}
+	comment.Set(nil)
+	if comment.IsSet() || comment.ContentTable.Valid || comment.ObjectID.Valid {
+		t.Error("Expected a cleared generic relation", comment.GenericRelation)
+	}
+	values, err := simpledb.DBValues(comment, []string{"content_table", "object_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range values {
+		if value, err := value.(driver.Valuer).Value(); err != nil || value != nil {
+			t.Error("Expected NULL for a cleared generic relation", value, err)
+		}
+	}
+}
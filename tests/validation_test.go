@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Nigel2392/simpledb"
+)
+
+type ValidatedModel struct {
+	ID     int64   `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Name   string  `simpledb:"LENGTH:5"`
+	Email  *string `simpledb:"LENGTH:255,REGEX:^[^@]+@[^@]+$"`
+	Phone  *string `simpledb:"LENGTH:32,NULLABLE:false"`
+	Age    int     `simpledb:"MIN:0,MAX:150"`
+	Small  int     `simpledb:"TYPE:TINYINT"`
+	Status string  `simpledb:"LENGTH:16,CHOICES:draft|published"`
+}
+
+func (m *ValidatedModel) TableName() string {
+	return "validated_model"
+}
+
+func TestValidate(t *testing.T) {
+	var email = "john@example.com"
+	var phone = "0612345678"
+	var model = &ValidatedModel{Name: "John", Email: &email, Phone: &phone, Age: 30, Small: 10, Status: "draft"}
+	if err := mDB.Validate(model); err != nil {
+		t.Error("Expected no errors, got", err)
+	}
+
+	var invalid = "invalid"
+	model = &ValidatedModel{Name: "Johnny", Email: &invalid, Phone: &phone, Age: -1, Small: 200, Status: "deleted"}
+	err := mDB.Validate(model)
+	var errs simpledb.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatal("Expected ValidationErrors, got", err)
+	}
+	for _, field := range []string{"name", "email", "age", "small", "status"} {
+		if !errs.Has(field) {
+			t.Error("Expected an error for", field)
+		}
+	}
+
+	// Pointers are nullable columns, unless NULLABLE:false is set.
+	model = &ValidatedModel{Name: "John", Phone: &phone, Status: "published"}
+	if err := mDB.Validate(model); err != nil {
+		t.Error("Expected no errors for a nil pointer, got", err)
+	}
+	model = &ValidatedModel{Name: "John", Email: &email, Status: "published"}
+	err = mDB.Validate(model)
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Tag != "NULLABLE" || !errs.Has("phone") {
+		t.Error("Expected a NULLABLE error, got", err)
+	}
+}
+
+type CodeModel struct {
+	ID   int64  `simpledb:"RAW:NOT NULL PRIMARY KEY AUTO_INCREMENT"`
+	Code string `simpledb:"LENGTH:8,REGEX:^\\d{1\\,3}$"`
+}
+
+func (m *CodeModel) TableName() string {
+	return "code_model"
+}
+
+func TestRegexWithComma(t *testing.T) {
+	if err := mDB.Validate(&CodeModel{Code: "123"}); err != nil {
+		t.Error("Expected no errors, got", err)
+	}
+	if err := mDB.Validate(&CodeModel{Code: "1234"}); err == nil {
+		t.Error("Expected a REGEX error")
+	}
+	var f, _ = reflect.TypeOf(CodeModel{}).FieldByName("Code")
+	if tags := simpledb.TagMap(f); tags.Get("REGEX") != `^\d{1,3}$` || tags.Length() != 8 {
+		t.Error("Unexpected tags", tags)
+	}
+}
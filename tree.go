@@ -0,0 +1,127 @@
+package simpledb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Get the tree relation of a model, a self-referential belongs-to relation with the TREE tag.
+// The field of the relation must be a pointer to the model.
+func treeRelation(meta *ModelMeta) (*FieldMeta, error) {
+	for _, f := range meta.ForeignKeys {
+		if f.Tags.Tree() {
+			if relationTarget(f) != meta.Table {
+				return nil, errors.New("tree relation " + f.Name + " of model " + meta.Table + " must point to the model itself")
+			}
+			if f.Type != reflect.PointerTo(meta.Type) {
+				return nil, errors.New("tree relation " + f.Name + " of model " + meta.Table + " must be a pointer to " + meta.Type.Name() + ", got " + f.Type.String())
+			}
+			return f, nil
+		}
+	}
+	return nil, errors.New("model " + meta.Table + " has no tree relation")
+}
+
+// Get the children of a node in a tree as a QuerySet.
+// The model must have a tree relation, see ModelTags.Tree.
+func (db *Database) Children(ctx context.Context, node Model) (*QuerySet, error) {
+	meta := db.meta(node)
+	f, err := treeRelation(meta)
+	if err != nil {
+		return nil, err
+	}
+	_, pk := primaryKey(db.meta(node), node)
+	qs := NewQuerySet(db, NewModel(node)).WithContext(ctx)
+	return qs.All().Where(f.Column, EQ, pk), nil
+}
+
+// Get the ancestors of a node in a tree, starting at the parent and ending at the root.
+// The ancestors are selected with a recursive common table expression.
+func (db *Database) Ancestors(ctx context.Context, node Model) (ModelSet, error) {
+	meta := db.meta(node)
+	f, err := treeRelation(meta)
+	if err != nil {
+		return nil, err
+	}
+	_, pk := primaryKey(db.meta(node), node)
+	query := fmt.Sprintf(`WITH RECURSIVE tree AS (
+		SELECT t.*, 1 AS tree__depth FROM %[1]s t JOIN %[1]s c ON t.%[2]s = c.%[3]s WHERE c.%[2]s = ?
+		UNION ALL
+		SELECT t.*, tree.tree__depth + 1 FROM %[1]s t JOIN tree ON t.%[2]s = tree.%[3]s
+	) SELECT * FROM tree ORDER BY tree__depth`, meta.Table, meta.PrimaryColumn(), f.Column)
+	return db.selectTree(ctx, node, query, pk)
+}
+
+// Get the descendants of a node in a tree, ordered by depth.
+// The descendants are selected with a recursive common table expression.
+func (db *Database) Descendants(ctx context.Context, node Model) (ModelSet, error) {
+	meta := db.meta(node)
+	f, err := treeRelation(meta)
+	if err != nil {
+		return nil, err
+	}
+	_, pk := primaryKey(db.meta(node), node)
+	query := fmt.Sprintf(`WITH RECURSIVE tree AS (
+		SELECT t.*, 1 AS tree__depth FROM %[1]s t WHERE t.%[3]s = ?
+		UNION ALL
+		SELECT t.*, tree.tree__depth + 1 FROM %[1]s t JOIN tree ON t.%[3]s = tree.%[2]s
+	) SELECT * FROM tree ORDER BY tree__depth`, meta.Table, meta.PrimaryColumn(), f.Column)
+	return db.selectTree(ctx, node, query, pk)
+}
+
+// Execute a tree query, and scan the rows into models of the type of the node.
+func (db *Database) selectTree(ctx context.Context, node Model, query string, args ...any) (ModelSet, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRows(ctx, db, db.meta(node), rows, node, nil), nil
+}
+
+// Move a node, together with its descendants, to a new parent.
+// A nil parent moves the node to the root of the tree.
+// Returns an error if the new parent is the node itself, one of its descendants, or not of the type of the node.
+func (db *Database) MoveSubtree(ctx context.Context, node Model, parent Model) error {
+	meta := db.meta(node)
+	f, err := treeRelation(meta)
+	if err != nil {
+		return err
+	}
+	column, pk := primaryKey(db.meta(node), node)
+	var parent_pk any
+	if parent != nil && !reflect.ValueOf(parent).IsNil() {
+		if !reflect.TypeOf(parent).AssignableTo(f.Type) {
+			return errors.New("cannot move a node of " + meta.Table + " to a parent of type " + reflect.TypeOf(parent).String())
+		}
+		_, parent_pk = primaryKey(db.meta(parent), parent)
+	}
+	return db.Transaction(ctx, func(tx *Database) error {
+		if parent_pk != nil {
+			if fmt.Sprint(parent_pk) == fmt.Sprint(pk) {
+				return errors.New("cannot move a node to itself")
+			}
+			descendants, err := tx.Descendants(ctx, node)
+			if err != nil {
+				return err
+			}
+			for _, d := range descendants {
+				if _, d_pk := primaryKey(db.meta(d), d); fmt.Sprint(d_pk) == fmt.Sprint(parent_pk) {
+					return errors.New("cannot move a node to one of its descendants")
+				}
+			}
+		}
+		query := `UPDATE ` + meta.Table + ` SET ` + f.Column + ` = ? WHERE ` + column + ` = ?`
+		if _, err := tx.ExecContext(ctx, query, parent_pk, pk); err != nil {
+			return err
+		}
+		if parent_pk == nil {
+			setForeignKey(f, reflect.ValueOf(node).Elem(), nil)
+		} else {
+			f.value(reflect.ValueOf(node).Elem()).Set(reflect.ValueOf(parent))
+		}
+		return nil
+	})
+}
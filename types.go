@@ -0,0 +1,96 @@
+package simpledb
+
+import (
+	"database/sql"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ColumnTyper can be implemented by custom types to declare their column type.
+// This is mostly useful for types implementing sql.Scanner and driver.Valuer.
+// Example:
+//
+//	type Point struct{ X, Y float64 }
+//
+//	func (p Point) ColumnType() simpledb.DBType { return "POINT" }
+type ColumnTyper interface {
+	ColumnType() DBType
+}
+
+// Registry of custom column types, reflect.Type -> DBType
+var typeRegistry sync.Map
+
+// Register the column type for a golang type.
+// This takes precedence over ColumnTyper, and the default types.
+// Example:
+//
+//	simpledb.RegisterType(decimal.Decimal{}, "DECIMAL(10,2)")
+func RegisterType(v any, typ DBType) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	typeRegistry.Store(t, typ)
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	bytesType       = reflect.TypeOf([]byte{})
+	columnTyperType = reflect.TypeOf((*ColumnTyper)(nil)).Elem()
+)
+
+// Column types of the nullable types in database/sql.
+var sqlNullTypes = map[reflect.Type]DBType{
+	reflect.TypeOf(sql.NullString{}):  VARCHAR,
+	reflect.TypeOf(sql.NullBool{}):    BOOLEAN,
+	reflect.TypeOf(sql.NullByte{}):    TINYINT,
+	reflect.TypeOf(sql.NullInt16{}):   SMALLINT,
+	reflect.TypeOf(sql.NullInt32{}):   INT,
+	reflect.TypeOf(sql.NullInt64{}):   BIGINT,
+	reflect.TypeOf(sql.NullFloat64{}): DOUBLE,
+	reflect.TypeOf(sql.NullTime{}):    DATETIME,
+}
+
+// Column types of the unsigned integer kinds.
+var unsignedTypes = map[reflect.Kind]DBType{
+	reflect.Uint8:  TINYINT,
+	reflect.Uint16: SMALLINT,
+	reflect.Uint32: INT,
+	reflect.Uint:   INT,
+	reflect.Uint64: BIGINT,
+}
+
+// Get the MySQL column type for a golang type.
+// Also reports if the column should be nullable (pointers and sql.Null* types),
+// and if the column should be unsigned (unsigned integers).
+func GoColumnType(t reflect.Type) (typ DBType, nullable bool, unsigned bool) {
+	if t.Kind() == reflect.Ptr {
+		typ, _, unsigned = GoColumnType(t.Elem())
+		return typ, true, unsigned
+	}
+	if v, ok := typeRegistry.Load(t); ok {
+		return v.(DBType), false, false
+	}
+	if t.Implements(columnTyperType) {
+		return reflect.Zero(t).Interface().(ColumnTyper).ColumnType(), false, false
+	} else if reflect.PtrTo(t).Implements(columnTyperType) {
+		return reflect.New(t).Interface().(ColumnTyper).ColumnType(), false, false
+	}
+	if typ, ok := sqlNullTypes[t]; ok {
+		return typ, true, t == reflect.TypeOf(sql.NullByte{})
+	}
+	switch t {
+	case timeType:
+		return DATETIME, false, false
+	case durationType:
+		return BIGINT, false, false
+	case bytesType:
+		return BLOB, false, false
+	}
+	if typ, ok := unsignedTypes[t.Kind()]; ok {
+		return typ, false, true
+	}
+	return DBType(GetColType(t.Kind().String(), "mysql")), false, false
+}
@@ -0,0 +1,212 @@
+package simpledb
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is returned for a single field which did not pass validation.
+type FieldError struct {
+	Field   string
+	Tag     string
+	Message string
+}
+
+// Error string for the field error.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors is a list of errors for all fields which did not pass validation.
+type ValidationErrors []FieldError
+
+// Error string for the validation errors.
+func (e ValidationErrors) Error() string {
+	var errs = make([]string, len(e))
+	for i, err := range e {
+		errs[i] = err.Error()
+	}
+	return "validation failed: " + strings.Join(errs, ", ")
+}
+
+// Check if a field has any errors.
+func (e ValidationErrors) Has(field string) bool {
+	for _, err := range e {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranges for the integer column types.
+var intRanges = map[DBType][2]int64{
+	TINYINT:  {math.MinInt8, math.MaxInt8},
+	SMALLINT: {math.MinInt16, math.MaxInt16},
+	INT:      {math.MinInt32, math.MaxInt32},
+	BIGINT:   {math.MinInt64, math.MaxInt64},
+}
+
+// Maximum values for the unsigned integer column types.
+var uintRanges = map[DBType]uint64{
+	TINYINT:  math.MaxUint8,
+	SMALLINT: math.MaxUint16,
+	INT:      math.MaxUint32,
+	BIGINT:   math.MaxUint64,
+}
+
+// Compiled REGEX tags, so we only have to compile them once.
+var regexCache sync.Map
+
+// Validate a model against its tags before writing it to the database.
+// See ValidateModel.
+func (db *Database) Validate(model Model) error {
+	return validateModel(db.meta(model), model)
+}
+
+// Validate a model against its tags.
+// The following tags are checked:
+//
+//	LENGTH:   Maximum length of a string.
+//	NULLABLE: Pointer fields may only be nil if the column is nullable, pointers are nullable unless NULLABLE:false is set.
+//	TYPE:     Integers must fit in the range of the (UNSIGNED) column type.
+//	MIN:      Minimum value of a number.
+//	MAX:      Maximum value of a number.
+//	REGEX:    A string must match the regular expression.
+//	CHOICES:  The value must be one of the choices, separated by a pipe. (CHOICES:draft|published)
+//	VALUES:   The value of an ENUM column must be one of the values.
+//
+// Returns ValidationErrors if any of the fields are invalid.
+func ValidateModel(model Model) error {
+	return validateModel(GetMeta(model), model)
+}
+
+// See ValidateModel.
+func validateModel(meta *ModelMeta, model Model) error {
+	var errs = ValidationErrors{}
+	var value = reflect.ValueOf(model)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	for _, f := range meta.Columns {
+		var tm = f.Tags
+		var name = f.Column
+		var addErr = func(tag, msg string) {
+			errs = append(errs, FieldError{Field: name, Tag: tag, Message: msg})
+		}
+		var field = f.value(value)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				if !f.nullable() && !tm.Auto() && tm.Default() == "" {
+					addErr("NULLABLE", "cannot be null")
+				}
+				continue
+			}
+			field = field.Elem()
+		}
+		switch field.Kind() {
+		case reflect.String:
+			var s = field.String()
+			if tm.Length() > 0 && utf8.RuneCountInString(s) > tm.Length() {
+				addErr("LENGTH", fmt.Sprintf("length must be at most %d", tm.Length()))
+			}
+			if tm.Has("REGEX") {
+				re, err := compileRegex(tm.Get("REGEX"))
+				if err != nil {
+					addErr("REGEX", "invalid regular expression: "+err.Error())
+				} else if !re.MatchString(s) {
+					addErr("REGEX", "does not match "+re.String())
+				}
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n = field.Int()
+			var typ = columnType(f)
+			if tm.Unsigned() && n < 0 {
+				addErr("TYPE", fmt.Sprintf("value out of range for %s UNSIGNED", typ))
+			} else if r, ok := intRanges[typ]; ok && !tm.Unsigned() && (n < r[0] || n > r[1]) {
+				addErr("TYPE", fmt.Sprintf("value out of range for %s", typ))
+			} else if max, ok := uintRanges[typ]; ok && tm.Unsigned() && uint64(n) > max {
+				addErr("TYPE", fmt.Sprintf("value out of range for %s UNSIGNED", typ))
+			}
+			validateMinMax(float64(n), tm, addErr)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var n = field.Uint()
+			var typ = columnType(f)
+			if max, ok := uintRanges[typ]; ok && n > max {
+				addErr("TYPE", fmt.Sprintf("value out of range for %s UNSIGNED", typ))
+			}
+			validateMinMax(float64(n), tm, addErr)
+		case reflect.Float32, reflect.Float64:
+			validateMinMax(field.Float(), tm, addErr)
+		}
+		if tm.Has("CHOICES") || (columnType(f) == ENUM && tm.Has("VALUES")) {
+			var v = fmt.Sprint(field.Interface())
+			var choices = tm.Values()
+			if tm.Has("CHOICES") {
+				choices = strings.Split(tm.Get("CHOICES"), "|")
+			}
+			var found bool
+			for _, c := range choices {
+				if c == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				addErr("CHOICES", "must be one of "+strings.Join(choices, ", "))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Get the column type of a field, used to check the range of integers.
+func columnType(f *FieldMeta) DBType {
+	if f.Tags.Type() != "" {
+		return DBType(strings.ToUpper(f.Tags.Type()))
+	}
+	typ, _, _ := GoColumnType(f.Type)
+	return typ
+}
+
+// Validate the MIN and MAX tags for a number.
+func validateMinMax(n float64, tm ModelTags, addErr func(tag, msg string)) {
+	if tm.Has("MIN") {
+		min, err := strconv.ParseFloat(tm.Get("MIN"), 64)
+		if err != nil {
+			addErr("MIN", "invalid MIN tag: "+tm.Get("MIN"))
+		} else if n < min {
+			addErr("MIN", "must be at least "+tm.Get("MIN"))
+		}
+	}
+	if tm.Has("MAX") {
+		max, err := strconv.ParseFloat(tm.Get("MAX"), 64)
+		if err != nil {
+			addErr("MAX", "invalid MAX tag: "+tm.Get("MAX"))
+		} else if n > max {
+			addErr("MAX", "must be at most "+tm.Get("MAX"))
+		}
+	}
+}
+
+// Compile a regular expression, or get it from the cache.
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, re)
+	return re, nil
+}